@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"userapi/internal/auth"
 	"userapi/internal/config"
 	connect "userapi/internal/db"
 	"userapi/internal/handler"
 	"userapi/internal/kafka"
+	"userapi/internal/lifecycle"
 	"userapi/internal/logger"
+	"userapi/internal/mail"
 	"userapi/internal/middleware"
+	"userapi/internal/outbox"
+	"userapi/internal/ratelimit"
 	"userapi/internal/redisdb"
 	"userapi/internal/repository"
 	"userapi/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
@@ -24,10 +37,14 @@ func main() {
 		logger.Log.Fatal("Failed to load .env file", zap.Error(err))
 	}
 
-	jwtKey, err := config.GetJwtKey()
-	if err != nil {
-		logger.Log.Fatal("JWT_KEY error", zap.Error(err))
-	}
+	keys := loadKeySet()
+
+	tokenValidator := service.NewTokenValidator(keys, service.TokenValidatorConfig{
+		Algorithms: config.GetJwtAllowedAlgs(),
+		Issuer:     config.GetJwtIssuer(),
+		Audience:   config.GetJwtAudience(),
+		Leeway:     config.GetJwtLeeway(),
+	})
 
 	dsn, err := config.GetDBDsn()
 	if err != nil {
@@ -56,48 +73,305 @@ func main() {
 		topic,
 	)
 
-	defer kafkaProducer.Close()
+	tasks := lifecycle.NewTasks()
 
 	repo := repository.NewUserRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
 	validator := service.NewValidator(repo)
 	redisService := service.NewRedisClient(redisClient)
-	userService := service.NewUserService(repo, redisService, jwtKey)
+	outboxRepo := repository.NewOutboxRepository(db)
+	roleService := service.NewRoleService(roleRepo, outboxRepo, redisService)
+
+	passwordPolicy := service.PasswordPolicy{
+		MinLength:    config.GetPasswordMinLength(),
+		MaxLength:    service.MaxPasswordLength,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+		HistorySize:  config.GetPasswordHistorySize(),
+	}
+
+	twoFactorService := service.NewTwoFactorService(
+		repo,
+		repository.NewRecoveryCodeRepository(db),
+		redisService,
+		newSecretBox(),
+	)
+
+	userService := service.NewUserService(
+		repo,
+		repository.NewMySQLUserSearcher(db),
+		repository.NewPasswordHistoryRepository(),
+		outboxRepo,
+		roleService,
+		twoFactorService,
+		passwordPolicy,
+		newMailer(),
+		redisService,
+		keys,
+		tasks,
+	)
+
+	if err := roleService.EnsureDefaultRoles(); err != nil {
+		logger.Log.Fatal("Failed to seed default roles", zap.Error(err))
+	}
 
 	if err := userService.EnsureDefaultAdmin(); err != nil {
 		logger.Log.Fatal("Failed to create default admin", zap.Error(err))
 	}
 
-	handler := handler.NewUserHandler(userService, validator, redisService, kafkaProducer)
+	outboxConfig := config.GetOutboxConfig()
+	relay := outbox.NewRelay(outboxRepo, kafkaProducer, outbox.Config{
+		BatchSize:    outboxConfig.BatchSize,
+		PollInterval: outboxConfig.PollInterval,
+		MaxAttempts:  outboxConfig.MaxAttempts,
+		Retention:    outboxConfig.Retention,
+	})
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	keysHandler := handler.NewKeysHandler(keys)
+	roleHandler := handler.NewRoleHandler(roleService, validator)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService, validator)
+
+	revocationClients := config.GetRevocationClients()
+	if len(revocationClients) == 0 {
+		logger.Log.Warn("TOKEN_REVOCATION_CLIENTS not set, token revocation endpoint rejects every client")
+	}
+
+	tokenHandler := handler.NewTokenHandler(service.NewTokenRevoker(tokenValidator, redisService), revocationClients)
+	lockout := config.GetLockoutConfig()
+	loginGuard := service.NewLoginGuard(redisService, service.LockoutPolicy{
+		MaxAttempts:   lockout.MaxAttempts,
+		MaxIPAttempts: lockout.MaxIPAttempts,
+		Window:        lockout.Window,
+		BaseLockout:   lockout.BaseLockout,
+		MaxLockout:    lockout.MaxLockout,
+	})
+
+	handler := handler.NewUserHandler(userService, validator, kafkaProducer, loginGuard, tasks)
+
+	limiter := newLimiter(redisClient)
+	registerLimit := rateLimit(limiter, "register", "5/1m", "ip")
+	loginLimit := rateLimit(limiter, "login", "10/1m", "ip")
 
 	r := gin.New()
-	r.Use(gin.Logger(), middleware.ErrorRecovery())
+	r.Use(gin.Logger(), middleware.ErrorRecovery(), middleware.RequestID())
 
-	r.POST("/register", handler.RegisterUser)
-	r.POST("/login", handler.Login)
-	r.POST("/logout", handler.Logout)
+	r.GET("/.well-known/jwks.json", keysHandler.JWKS)
+
+	r.POST("/register", registerLimit, handler.RegisterUser)
+	r.POST("/login", loginLimit, handler.Login)
+	r.POST("/login/2fa", loginLimit, handler.LoginTOTP)
+	r.POST("/token/refresh", handler.Refresh)
+	r.POST("/token/revoke", tokenHandler.Revoke)
+	r.POST("/password/forgot", handler.ForgotPassword)
+	r.POST("/password/reset", handler.ResetPassword)
+	r.GET("/verify-email", handler.VerifyEmail)
 
 	authUser := r.Group("/")
-	authUser.Use(middleware.JWTMiddleware(jwtKey, redisService))
+	authUser.Use(middleware.JWTMiddleware(tokenValidator, redisService))
 	{
-		authUser.PUT("/users/:login", handler.UpdateProfile)
+		ownsLogin := middleware.RequireOwnerOrAdmin(middleware.UserByLoginParam(userService, "login"))
+
+		authUser.POST("/logout", tokenHandler.Logout)
+		authUser.GET("/users/me", handler.GetMe)
+		authUser.GET("/users/:login", handler.GetProfile)
+		authUser.PUT("/users/:login", ownsLogin, handler.UpdateProfile)
+		authUser.PATCH("/users/:login", ownsLogin, handler.PatchProfile)
+		authUser.POST("/users/me/password", handler.ChangePassword)
+		authUser.POST("/users/me/email/verification", handler.ResendVerification)
+		authUser.POST("/users/me/2fa/enroll", twoFactorHandler.Enroll)
+		authUser.POST("/users/me/2fa/confirm", twoFactorHandler.Confirm)
+		authUser.POST("/users/me/2fa/disable", twoFactorHandler.Disable)
+		authUser.GET("/users/me/sessions", handler.ListSessions)
+		authUser.DELETE("/users/me/sessions/:id", handler.RevokeSession)
 	}
 
 	authAdmin := r.Group("/admin")
 	authAdmin.Use(
-		middleware.JWTMiddleware(jwtKey, redisService),
-		middleware.RequireAdmin(),
+		middleware.JWTMiddleware(tokenValidator, redisService),
+		rateLimit(limiter, "admin", "120/1m", "user"),
 	)
 	{
-		authAdmin.POST("/register", handler.RegisterAdmin)
-		authAdmin.GET("/users", handler.GetAll)
-		authAdmin.GET("/users/:login", handler.GetByLogin)
-		authAdmin.PUT("/users/:login", handler.Update)
-		authAdmin.DELETE("/users/:id", handler.Delete)
+		authAdmin.POST("/register", middleware.RequirePermission(auth.PermUsersCreate), handler.RegisterAdmin)
+		authAdmin.GET("/users", middleware.RequirePermission(auth.PermUsersRead), handler.GetAll)
+		authAdmin.GET("/users/search", middleware.RequirePermission(auth.PermUsersRead), handler.Search)
+		authAdmin.GET("/users/:login", middleware.RequirePermission(auth.PermUsersRead), handler.GetByLogin)
+		authAdmin.PUT(
+			"/users/:login",
+			middleware.RequirePermission(auth.PermUsersUpdate),
+			middleware.ResolveTarget(middleware.UserByLoginParam(userService, "login")),
+			handler.Update,
+		)
+		authAdmin.PATCH(
+			"/users/:login",
+			middleware.RequirePermission(auth.PermUsersUpdate),
+			middleware.ResolveTarget(middleware.UserByLoginParam(userService, "login")),
+			handler.Patch,
+		)
+		authAdmin.DELETE("/users/:id", middleware.RequirePermission(auth.PermUsersDelete), handler.Delete)
+		authAdmin.POST("/users/:id/unlock", middleware.RequirePermission(auth.PermUsersUpdate), handler.Unlock)
+		authAdmin.POST("/users/:id/revoke", middleware.RequirePermission(auth.PermUsersDelete), handler.Revoke)
+		authAdmin.POST("/users/:id/restore", middleware.RequirePermission(auth.PermUsersDelete), handler.Restore)
+		authAdmin.DELETE("/users/:id/sessions", middleware.RequirePermission(auth.PermUsersUpdate), handler.RevokeAllSessions)
+
+		authAdmin.POST("/users/:id/roles", middleware.RequirePermission(auth.PermRolesManage), roleHandler.AssignToUser)
+		authAdmin.DELETE("/users/:id/roles/:role", middleware.RequirePermission(auth.PermRolesManage), roleHandler.RemoveFromUser)
+
+		authAdmin.GET("/roles", middleware.RequirePermission(auth.PermRolesManage), roleHandler.GetAll)
+		authAdmin.POST("/roles", middleware.RequirePermission(auth.PermRolesManage), roleHandler.Create)
+		authAdmin.PUT("/roles/:name/permissions", middleware.RequirePermission(auth.PermRolesManage), roleHandler.SetPermissions)
+		authAdmin.DELETE("/roles/:name", middleware.RequirePermission(auth.PermRolesManage), roleHandler.Delete)
+		authAdmin.GET("/permissions", middleware.RequirePermission(auth.PermRolesManage), roleHandler.GetPermissions)
+	}
+
+	server := &http.Server{
+		Addr:              port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+
+	go func() {
+		logger.Log.Info("Starting HTTP server", zap.String("port", port))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	failed := false
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Log.Error("Failed to run server", zap.Error(err))
+			failed = true
+		}
+	case <-ctx.Done():
+		logger.Log.Info("Shutting down")
+	}
+
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetShutdownTimeout())
+
+	// Order matters: requests and background tasks still publish to Kafka
+	// and use Redis and the database, so those are closed last.
+	lifecycle.Shutdown(shutdownCtx,
+		lifecycle.Hook{Name: "http server", Fn: server.Shutdown},
+		lifecycle.Hook{Name: "background tasks", Fn: tasks.Wait},
+		lifecycle.Hook{Name: "outbox relay", Fn: func(ctx context.Context) error {
+			stopRelay()
+
+			select {
+			case <-relayDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}},
+		lifecycle.Closer("kafka producer", kafkaProducer.Close),
+		lifecycle.Closer("redis", redisClient.Close),
+		lifecycle.Closer("database", func() error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+
+			return sqlDB.Close()
+		}),
+	)
+
+	cancel()
+
+	// os.Exit skips deferred calls, hence the explicit sync.
+	if failed {
+		logger.Log.Sync()
+		os.Exit(1)
+	}
+}
+
+func loadKeySet() *service.KeySet {
+	dir := config.GetJwtKeysDir()
+
+	if dir == "" {
+		jwtKey, err := config.GetJwtKey()
+		if err != nil {
+			logger.Log.Fatal("JWT_KEY error", zap.Error(err))
+		}
+
+		logger.Log.Warn("JWT_KEYS_DIR not set, falling back to HS256 with JWT_KEY")
+
+		return service.NewHMACKeySet(jwtKey)
+	}
+
+	kid, err := config.GetJwtActiveKid()
+	if err != nil {
+		logger.Log.Fatal("JWT_ACTIVE_KID error", zap.Error(err))
+	}
+
+	keys, err := service.LoadKeySet(dir, kid)
+	if err != nil {
+		logger.Log.Fatal("Failed to load JWT keys", zap.Error(err))
+	}
+
+	return keys
+}
+
+func newSecretBox() *service.SecretBox {
+	key, err := config.GetTOTPEncryptionKey()
+	if err != nil {
+		logger.Log.Fatal("TOTP_ENCRYPTION_KEY error", zap.Error(err))
 	}
 
-	logger.Log.Info("Starting HTTP server", zap.String("port", port))
+	box, err := service.NewSecretBox(key)
+	if err != nil {
+		logger.Log.Fatal("Failed to init TOTP encryption", zap.Error(err))
+	}
+
+	return box
+}
+
+func newMailer() mail.Mailer {
+	mailer, err := mail.NewFromConfig()
+	if err != nil {
+		logger.Log.Fatal("SMTP config error", zap.Error(err))
+	}
 
-	if err := r.Run(port); err != nil {
-		logger.Log.Fatal("Failed to run server", zap.Error(err))
+	return mailer
+}
+
+func newLimiter(client *redis.Client) ratelimit.Limiter {
+	if config.GetRateLimitBackend() == "memory" {
+		logger.Log.Warn("Using in-memory rate limiter, limits are not shared between instances")
+		return ratelimit.NewMemoryLimiter()
 	}
+
+	return ratelimit.NewRedisLimiter(client)
+}
+
+func rateLimit(limiter ratelimit.Limiter, group, spec, key string) gin.HandlerFunc {
+	cfg := config.GetRateLimit(group, spec, key)
+
+	policy, err := ratelimit.ParsePolicy(group, cfg.Spec)
+	if err != nil {
+		logger.Log.Fatal("Rate limit config error", zap.Error(err))
+	}
+
+	keyFunc, err := middleware.KeyFuncByName(cfg.Key)
+	if err != nil {
+		logger.Log.Fatal("Rate limit config error", zap.String("group", group), zap.Error(err))
+	}
+
+	return middleware.RateLimit(limiter, policy, keyFunc)
 }
@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"syscall"
 	"userapi/internal/config"
+	"userapi/internal/consumer"
+	connect "userapi/internal/db"
 	"userapi/internal/kafka"
+	"userapi/internal/lifecycle"
 	"userapi/internal/logger"
+	"userapi/internal/mail"
+	"userapi/internal/repository"
 
 	"go.uber.org/zap"
 )
@@ -13,6 +21,10 @@ func main() {
 	logger.InitLogger()
 	defer logger.Log.Sync()
 
+	if err := config.LoadEnv(); err != nil {
+		logger.Log.Fatal("Failed to load .env file", zap.Error(err))
+	}
+
 	broker, err := config.GetKafkaBroker()
 	if err != nil {
 		logger.Log.Fatal("failed to load broker", zap.Error(err))
@@ -23,11 +35,69 @@ func main() {
 		logger.Log.Fatal("failed to load topic", zap.Error(err))
 	}
 
-	consumer := kafka.NewConsumer(broker, topic)
+	dsn, err := config.GetDBDsn()
+	if err != nil {
+		logger.Log.Fatal("DB_DSN error", zap.Error(err))
+	}
+
+	db := connect.InitDB(dsn)
+
+	mailer, err := mail.NewFromConfig()
+	if err != nil {
+		logger.Log.Fatal("SMTP config error", zap.Error(err))
+	}
+
+	registry := kafka.NewRegistry()
+	consumer.NewHandlers(
+		mailer,
+		repository.NewAuditRepository(db),
+		repository.NewEmailDeliveryRepository(db),
+	).Register(registry)
+
+	cfg := config.GetConsumerConfig(topic)
+
+	c := kafka.NewConsumer(kafka.ConsumerConfig{
+		Broker:      broker,
+		Topic:       topic,
+		GroupID:     cfg.GroupID,
+		DLQTopic:    cfg.DLQTopic,
+		MaxRetries:  cfg.MaxRetries,
+		BaseBackoff: cfg.BaseBackoff,
+		MaxBackoff:  cfg.MaxBackoff,
+	}, registry)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger.Log.Info("Kafka consumer started...", zap.String("topic", topic), zap.String("dlq", cfg.DLQTopic))
+
+	runErr := c.Start(ctx)
+	if runErr != nil {
+		logger.Log.Error("consumer failed", zap.Error(runErr))
+	}
+
+	stop()
+	logger.Log.Info("Kafka consumer stopped")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetShutdownTimeout())
+
+	lifecycle.Shutdown(shutdownCtx,
+		lifecycle.Closer("kafka consumer", c.Close),
+		lifecycle.Closer("database", func() error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+
+			return sqlDB.Close()
+		}),
+	)
 
-	logger.Log.Info("Kafka consumer started...")
+	cancel()
 
-	if err := consumer.Start(context.Background()); err != nil {
-		logger.Log.Fatal("consumer failed", zap.Error(err))
+	// os.Exit skips deferred calls, hence the explicit sync.
+	if runErr != nil {
+		logger.Log.Sync()
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"userapi/internal/config"
+	connect "userapi/internal/db"
+	"userapi/internal/logger"
+	"userapi/internal/migrate"
+
+	"go.uber.org/zap"
+)
+
+const usage = `usage: migrate [-dir path] <command>
+
+commands:
+  up            apply all pending migrations
+  down [n]      revert the last n migrations (default 1)
+  status        list migrations and whether they are applied
+  create <name> add an empty up/down pair to -dir
+`
+
+func main() {
+	logger.InitLogger()
+	defer logger.Log.Sync()
+
+	dir := flag.String("dir", "internal/migrate/migrations", "migration source directory, used by create")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		files, err := migrate.Create(*dir, args[1])
+		if err != nil {
+			logger.Log.Fatal("Failed to create migration", zap.Error(err))
+		}
+
+		for _, file := range files {
+			fmt.Println("created", file)
+		}
+
+		return
+	}
+
+	if err := config.LoadEnv(); err != nil {
+		logger.Log.Warn("No .env file, using the environment", zap.Error(err))
+	}
+
+	dsn, err := config.GetDBDsn()
+	if err != nil {
+		logger.Log.Fatal("DB_DSN error", zap.Error(err))
+	}
+
+	db, err := connect.Open(dsn)
+	if err != nil {
+		logger.Log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Log.Fatal("Failed to get database handle", zap.Error(err))
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		logger.Log.Fatal("Failed to load migrations", zap.Error(err))
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+
+		if err != nil {
+			logger.Log.Fatal("Migration failed", zap.Error(err))
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				logger.Log.Fatal("down expects a positive number of steps", zap.String("steps", args[1]))
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+
+		if err != nil {
+			logger.Log.Fatal("Migration failed", zap.Error(err))
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Log.Fatal("Failed to read migration status", zap.Error(err))
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+
+			switch {
+			case s.Dirty:
+				state = "DIRTY"
+			case s.AppliedOn != nil:
+				state = "applied " + s.AppliedOn.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
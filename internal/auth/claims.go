@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleUser    = "user"
+)
+
+const (
+	PermUsersRead   = "users:read"
+	PermUsersCreate = "users:create"
+	PermUsersUpdate = "users:update"
+	PermUsersDelete = "users:delete"
+	PermRolesManage = "roles:manage"
+)
+
+// ScopeMFAEnroll is the only scope of a session that must enrol in 2FA first.
+const ScopeMFAEnroll = "mfa:enroll"
+
+// Permissions lists every permission known to the API with its description.
+var Permissions = map[string]string{
+	PermUsersRead:   "View user accounts",
+	PermUsersCreate: "Register users and admins",
+	PermUsersUpdate: "Modify any user account",
+	PermUsersDelete: "Delete user accounts",
+	PermRolesManage: "Manage roles and role assignments",
+}
+
+// Claims is the payload of every access token. The user ID travels in the
+// registered "sub" claim and the token ID in "jti".
+type Claims struct {
+	jwt.RegisteredClaims
+	Login     string   `json:"login"`
+	Roles     []string `json:"roles"`
+	Scopes    []string `json:"scopes,omitempty"`
+	SessionID string   `json:"session_id"`
+}
+
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
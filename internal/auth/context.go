@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	claimsKey = "auth.claims"
+	targetKey = "auth.target"
+)
+
+func SetClaims(c *gin.Context, claims *Claims) {
+	c.Set(claimsKey, claims)
+}
+
+// ClaimsFrom returns the claims stored by JWTMiddleware, if the route is authenticated.
+func ClaimsFrom(c *gin.Context) (*Claims, bool) {
+	raw, exists := c.Get(claimsKey)
+	if !exists {
+		return nil, false
+	}
+
+	claims, ok := raw.(*Claims)
+
+	return claims, ok
+}
+
+func UserID(c *gin.Context) string {
+	if claims, ok := ClaimsFrom(c); ok {
+		return claims.Subject
+	}
+
+	return ""
+}
+
+func Login(c *gin.Context) string {
+	if claims, ok := ClaimsFrom(c); ok {
+		return claims.Login
+	}
+
+	return ""
+}
+
+func JTI(c *gin.Context) string {
+	if claims, ok := ClaimsFrom(c); ok {
+		return claims.ID
+	}
+
+	return ""
+}
+
+func SessionID(c *gin.Context) string {
+	if claims, ok := ClaimsFrom(c); ok {
+		return claims.SessionID
+	}
+
+	return ""
+}
+
+func HasRole(c *gin.Context, role string) bool {
+	claims, ok := ClaimsFrom(c)
+
+	return ok && claims.HasRole(role)
+}
+
+// CanGrantAdmin reports whether the caller may set or clear the legacy admin
+// flag, which counts as full membership in the admin role.
+func CanGrantAdmin(c *gin.Context) bool {
+	claims, ok := ClaimsFrom(c)
+
+	return ok && (claims.HasRole(RoleAdmin) || claims.HasScope(PermRolesManage))
+}
+
+// SetTargetUserID records the user a request acts on, as resolved by an authorization policy.
+func SetTargetUserID(c *gin.Context, id uuid.UUID) {
+	c.Set(targetKey, id)
+}
+
+func TargetUserID(c *gin.Context) uuid.UUID {
+	raw, exists := c.Get(targetKey)
+	if !exists {
+		return uuid.Nil
+	}
+
+	id, _ := raw.(uuid.UUID)
+
+	return id
+}
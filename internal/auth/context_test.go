@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCanGrantAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims *Claims
+		want   bool
+	}{
+		{"anonymous", nil, false},
+		{"users:update only", &Claims{Roles: []string{RoleUser, "support"}, Scopes: []string{PermUsersUpdate}}, false},
+		{"roles:manage", &Claims{Roles: []string{RoleUser}, Scopes: []string{PermRolesManage}}, true},
+		{"admin role", &Claims{Roles: []string{RoleUser, RoleAdmin}}, true},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		if tt.claims != nil {
+			SetClaims(c, tt.claims)
+		}
+
+		if got := CanGrantAdmin(c); got != tt.want {
+			t.Errorf("%s: CanGrantAdmin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
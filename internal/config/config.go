@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -23,6 +25,78 @@ func GetJwtKey() ([]byte, error) {
 	return []byte(key), nil
 }
 
+// GetJwtKeysDir returns the directory with <kid>.pem signing keys.
+// An empty value keeps the legacy HS256 mode based on JWT_KEY.
+func GetJwtKeysDir() string {
+	return os.Getenv("JWT_KEYS_DIR")
+}
+
+func GetJwtActiveKid() (string, error) {
+	kid := os.Getenv("JWT_ACTIVE_KID")
+
+	if kid == "" {
+		return "", fmt.Errorf("JWT_ACTIVE_KID must be set when JWT_KEYS_DIR is used")
+	}
+
+	return kid, nil
+}
+
+func GetJwtIssuer() string {
+	issuer := os.Getenv("JWT_ISSUER")
+
+	if issuer == "" {
+		return "userapi"
+	}
+
+	return issuer
+}
+
+func GetJwtAudience() string {
+	audience := os.Getenv("JWT_AUDIENCE")
+
+	if audience == "" {
+		return "userapi"
+	}
+
+	return audience
+}
+
+// GetJwtAllowedAlgs returns the pinned signing algorithms.
+// An empty result means "the algorithms of the configured keys".
+func GetJwtAllowedAlgs() []string {
+	s := os.Getenv("JWT_ALLOWED_ALGS")
+
+	if s == "" {
+		return nil
+	}
+
+	var algs []string
+
+	for _, alg := range strings.Split(s, ",") {
+		if alg = strings.TrimSpace(alg); alg != "" {
+			algs = append(algs, alg)
+		}
+	}
+
+	return algs
+}
+
+func GetJwtLeeway() time.Duration {
+	s := os.Getenv("JWT_LEEWAY_SECONDS")
+
+	if s == "" {
+		return 30 * time.Second
+	}
+
+	secs, err := strconv.Atoi(s)
+
+	if err != nil {
+		return 30 * time.Second
+	}
+
+	return time.Duration(secs) * time.Second
+}
+
 func GetDBDsn() (string, error) {
 	dsn := os.Getenv("DB_DSN")
 
@@ -43,6 +117,12 @@ func GetPort() string {
 	return ":" + port
 }
 
+// GetShutdownTimeout bounds the whole graceful shutdown: draining requests,
+// background work and closing connections.
+func GetShutdownTimeout() time.Duration {
+	return time.Duration(getInt("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+}
+
 func GetJwtExpiration() time.Duration {
 	s := os.Getenv("JWT_EXP_MINUTES")
 
@@ -59,6 +139,250 @@ func GetJwtExpiration() time.Duration {
 	return time.Duration(mins) * time.Minute
 }
 
+func GetRefreshExpiration() time.Duration {
+	s := os.Getenv("REFRESH_EXP_HOURS")
+
+	if s == "" {
+		return 30 * 24 * time.Hour
+	}
+
+	hours, err := strconv.Atoi(s)
+
+	if err != nil {
+		return 30 * 24 * time.Hour
+	}
+
+	return time.Duration(hours) * time.Hour
+}
+
+func GetPasswordMinLength() int {
+	n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+
+	if err != nil || n <= 0 {
+		return 8
+	}
+
+	return n
+}
+
+// GetPasswordHistorySize is how many previous passwords cannot be reused.
+func GetPasswordHistorySize() int {
+	n, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_SIZE"))
+
+	if err != nil || n < 0 {
+		return 5
+	}
+
+	return n
+}
+
+// GetMailDriver returns "smtp" or "log" (the default, for local development).
+func GetMailDriver() string {
+	driver := os.Getenv("MAIL_DRIVER")
+
+	if driver == "" {
+		return "log"
+	}
+
+	return driver
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func GetSMTPConfig() (SMTPConfig, error) {
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+
+	if cfg.Host == "" || cfg.From == "" {
+		return cfg, fmt.Errorf("SMTP_HOST and MAIL_FROM must be set for the smtp mail driver")
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+
+	return cfg, nil
+}
+
+func GetMailLogFile() string {
+	return os.Getenv("MAIL_LOG_FILE")
+}
+
+func GetPasswordResetURL() string {
+	url := os.Getenv("PASSWORD_RESET_URL")
+
+	if url == "" {
+		return "http://localhost:8080/password/reset"
+	}
+
+	return url
+}
+
+func GetPasswordResetTTL() time.Duration {
+	mins, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES"))
+
+	if err != nil || mins <= 0 {
+		return 30 * time.Minute
+	}
+
+	return time.Duration(mins) * time.Minute
+}
+
+func GetEmailVerifyURL() string {
+	url := os.Getenv("EMAIL_VERIFY_URL")
+
+	if url == "" {
+		return "http://localhost:8080/verify-email"
+	}
+
+	return url
+}
+
+func GetEmailVerifyTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("EMAIL_VERIFY_TTL_HOURS"))
+
+	if err != nil || hours <= 0 {
+		return 24 * time.Hour
+	}
+
+	return time.Duration(hours) * time.Hour
+}
+
+// RequireVerifiedEmail blocks login for users whose address is not verified yet.
+func RequireVerifiedEmail() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+
+	return required
+}
+
+// RequireAdmin2FA forces admins to enrol in TOTP before they get admin rights.
+func RequireAdmin2FA() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_ADMIN_2FA"))
+
+	return required
+}
+
+// GetTOTPEncryptionKey returns the base64 encoded AES-256 key that encrypts
+// the TOTP secrets stored in the database.
+func GetTOTPEncryptionKey() ([]byte, error) {
+	encoded := os.Getenv("TOTP_ENCRYPTION_KEY")
+
+	if encoded == "" {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY must be set in .env or environment")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY must be 32 bytes, base64 encoded")
+	}
+
+	return key, nil
+}
+
+type LockoutConfig struct {
+	MaxAttempts   int
+	MaxIPAttempts int
+	Window        time.Duration
+	BaseLockout   time.Duration
+	MaxLockout    time.Duration
+}
+
+func GetLockoutConfig() LockoutConfig {
+	return LockoutConfig{
+		MaxAttempts:   getInt("LOGIN_MAX_ATTEMPTS", 5),
+		MaxIPAttempts: getInt("LOGIN_MAX_IP_ATTEMPTS", 20),
+		Window:        time.Duration(getInt("LOGIN_FAILURE_WINDOW_MINUTES", 60)) * time.Minute,
+		BaseLockout:   time.Duration(getInt("LOGIN_LOCKOUT_BASE_SECONDS", 60)) * time.Second,
+		MaxLockout:    time.Duration(getInt("LOGIN_LOCKOUT_MAX_SECONDS", 3600)) * time.Second,
+	}
+}
+
+// GetRevocationClients reads TOKEN_REVOCATION_CLIENTS ("id:secret,id:secret"),
+// the services allowed to call the token revocation endpoint.
+func GetRevocationClients() map[string]string {
+	clients := make(map[string]string)
+
+	for _, entry := range strings.Split(os.Getenv("TOKEN_REVOCATION_CLIENTS"), ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+
+		if ok && id != "" && secret != "" {
+			clients[id] = secret
+		}
+	}
+
+	return clients
+}
+
+type RateLimitConfig struct {
+	Spec string
+	Key  string
+}
+
+// GetRateLimit reads RATE_LIMIT_<GROUP> ("<limit>/<window>") and
+// RATE_LIMIT_<GROUP>_KEY (ip, user or api_key) for one route group.
+func GetRateLimit(group, spec, key string) RateLimitConfig {
+	prefix := "RATE_LIMIT_" + strings.ToUpper(group)
+	cfg := RateLimitConfig{Spec: os.Getenv(prefix), Key: os.Getenv(prefix + "_KEY")}
+
+	if cfg.Spec == "" {
+		cfg.Spec = spec
+	}
+
+	if cfg.Key == "" {
+		cfg.Key = key
+	}
+
+	return cfg
+}
+
+// GetRateLimitBackend is "redis" (default) or "memory" for single-instance runs.
+func GetRateLimitBackend() string {
+	if backend := os.Getenv("RATE_LIMIT_BACKEND"); backend != "" {
+		return backend
+	}
+
+	return "redis"
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	Retention    time.Duration
+}
+
+// GetOutboxConfig configures the relay that publishes outbox rows to Kafka.
+// A retention of 0 keeps published rows forever.
+func GetOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		PollInterval: time.Duration(max(getInt("OUTBOX_POLL_INTERVAL_MS", 1000), 1)) * time.Millisecond,
+		BatchSize:    max(getInt("OUTBOX_BATCH_SIZE", 100), 1),
+		MaxAttempts:  max(getInt("OUTBOX_MAX_ATTEMPTS", 10), 1),
+		Retention:    time.Duration(getInt("OUTBOX_RETENTION_HOURS", 168)) * time.Hour,
+	}
+}
+
+func getInt(name string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+
+	if err != nil || n < 0 {
+		return fallback
+	}
+
+	return n
+}
+
 func GetKafkaBroker() (string, error) {
 	broker := os.Getenv("KAFKA_BROKER")
 
@@ -78,3 +402,33 @@ func GetKafkaTopic() (string, error) {
 
 	return topic, nil
 }
+
+type ConsumerConfig struct {
+	GroupID     string
+	DLQTopic    string
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// GetConsumerConfig configures cmd/consumer. The dead-letter topic defaults
+// to "<topic>.dlq".
+func GetConsumerConfig(topic string) ConsumerConfig {
+	cfg := ConsumerConfig{
+		GroupID:     os.Getenv("KAFKA_GROUP_ID"),
+		DLQTopic:    os.Getenv("KAFKA_DLQ_TOPIC"),
+		MaxRetries:  getInt("CONSUMER_MAX_RETRIES", 5),
+		BaseBackoff: time.Duration(max(getInt("CONSUMER_RETRY_BACKOFF_MS", 500), 1)) * time.Millisecond,
+		MaxBackoff:  time.Duration(max(getInt("CONSUMER_RETRY_MAX_BACKOFF_MS", 30000), 1)) * time.Millisecond,
+	}
+
+	if cfg.GroupID == "" {
+		cfg.GroupID = "user-consumer-group"
+	}
+
+	if cfg.DLQTopic == "" {
+		cfg.DLQTopic = topic + ".dlq"
+	}
+
+	return cfg
+}
@@ -0,0 +1,107 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"userapi/internal/kafka"
+	"userapi/internal/mail"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+)
+
+var auditedTypes = []string{
+	kafka.TypeUserRegistered,
+	kafka.TypeUserUpdated,
+	kafka.TypeUserDeleted,
+	kafka.TypeUserRevoked,
+	kafka.TypeUserRestored,
+	kafka.TypeUserRoleChanged,
+	kafka.TypeUserLoggedIn,
+	kafka.TypeUserLoginFailed,
+	kafka.TypeUserLocked,
+	kafka.TypeUserPasswordChanged,
+	kafka.TypeUserEmailVerified,
+}
+
+const welcomeEmail = "welcome"
+
+// Handlers are the side effects of the user events.
+type Handlers struct {
+	mailer     mail.Mailer
+	audit      repository.AuditRepository
+	deliveries repository.EmailDeliveryRepository
+}
+
+func NewHandlers(mailer mail.Mailer, audit repository.AuditRepository, deliveries repository.EmailDeliveryRepository) *Handlers {
+	return &Handlers{mailer: mailer, audit: audit, deliveries: deliveries}
+}
+
+// Register audits every catalogue event; user.registered also sends the
+// welcome email.
+func (h *Handlers) Register(r *kafka.Registry) {
+	for _, eventType := range auditedTypes {
+		r.Register(eventType, h.record)
+	}
+
+	kafka.On(r, h.userRegistered)
+}
+
+// userRegistered marks the welcome email as sent only after Send succeeds: a
+// failed send is retried, a redelivered event is not mailed again. A crash
+// between Send and MarkSent still sends it twice.
+func (h *Handlers) userRegistered(ctx context.Context, envelope kafka.Envelope, event kafka.UserRegisteredEvent) error {
+	if err := h.record(ctx, envelope); err != nil {
+		return err
+	}
+
+	if event.Email == "" {
+		return nil
+	}
+
+	sent, err := h.deliveries.Sent(ctx, envelope.EventID, welcomeEmail)
+	if err != nil || sent {
+		return err
+	}
+
+	err = h.mailer.Send(ctx, mail.Message{
+		To:      event.Email,
+		Subject: "Welcome",
+		Body:    fmt.Sprintf("Hi %s, your account has been created.", event.Login),
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.deliveries.MarkSent(ctx, envelope.EventID, welcomeEmail)
+}
+
+func (h *Handlers) record(ctx context.Context, envelope kafka.Envelope) error {
+	var subject struct {
+		UserID string `json:"user_id"`
+	}
+
+	if err := json.Unmarshal(envelope.Data, &subject); err != nil {
+		return kafka.Permanent(fmt.Errorf("decode %s: %w", envelope.Type, err))
+	}
+
+	record := &model.AuditRecord{
+		EventID:    envelope.EventID,
+		EventType:  envelope.Type,
+		Actor:      envelope.Actor,
+		OccurredOn: envelope.OccurredAt,
+		Payload:    envelope.Data,
+	}
+
+	if subject.UserID != "" {
+		record.UserID = &subject.UserID
+	}
+
+	if envelope.TraceID != "" {
+		record.TraceID = &envelope.TraceID
+	}
+
+	_, err := h.audit.Create(ctx, record)
+
+	return err
+}
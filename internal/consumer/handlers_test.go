@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"userapi/internal/kafka"
+	"userapi/internal/mail"
+	"userapi/internal/model"
+)
+
+type recordingMailer struct {
+	failures int
+	sent     []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mail.Message) error {
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("smtp unavailable")
+	}
+
+	m.sent = append(m.sent, msg)
+
+	return nil
+}
+
+type memoryAudit struct {
+	records map[string]model.AuditRecord
+}
+
+func (a *memoryAudit) Create(_ context.Context, record *model.AuditRecord) (bool, error) {
+	if _, ok := a.records[record.EventID]; ok {
+		return false, nil
+	}
+
+	a.records[record.EventID] = *record
+
+	return true, nil
+}
+
+type memoryDeliveries struct {
+	sent map[string]bool
+}
+
+func (d *memoryDeliveries) Sent(_ context.Context, eventID, kind string) (bool, error) {
+	return d.sent[eventID+"/"+kind], nil
+}
+
+func (d *memoryDeliveries) MarkSent(_ context.Context, eventID, kind string) error {
+	d.sent[eventID+"/"+kind] = true
+
+	return nil
+}
+
+func TestUserRegisteredRetriesTheWelcomeEmailUntilItIsSent(t *testing.T) {
+	mailer := &recordingMailer{failures: 1}
+	audit := &memoryAudit{records: map[string]model.AuditRecord{}}
+	h := NewHandlers(mailer, audit, &memoryDeliveries{sent: map[string]bool{}})
+
+	event := kafka.UserRegisteredEvent{UserID: "u-1", Login: "bob", Email: "bob@example.com"}
+
+	envelope, err := kafka.NewEnvelope(context.Background(), event)
+	if err != nil {
+		t.Fatalf("NewEnvelope: %v", err)
+	}
+
+	if err := h.userRegistered(context.Background(), envelope, event); err == nil {
+		t.Fatal("a failed send was reported as handled")
+	}
+
+	if len(audit.records) != 1 {
+		t.Fatalf("audit records = %d, want 1", len(audit.records))
+	}
+
+	// The retry and a later redelivery of the same event.
+	for i := 0; i < 2; i++ {
+		if err := h.userRegistered(context.Background(), envelope, event); err != nil {
+			t.Fatalf("attempt %d: %v", i+2, err)
+		}
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "bob@example.com" {
+		t.Fatalf("sent %v, want one welcome email to bob", mailer.sent)
+	}
+
+	if len(audit.records) != 1 {
+		t.Errorf("audit records = %d, want 1", len(audit.records))
+	}
+}
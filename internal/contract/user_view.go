@@ -0,0 +1,77 @@
+package contract
+
+import (
+	"userapi/internal/dto"
+	"userapi/internal/model"
+)
+
+// The To*User functions are the only way a model.User leaves the API, so
+// credentials and second-factor secrets never reach a response or the cache.
+
+func ToPublicUser(user *model.User) dto.PublicUserResponse {
+	return dto.PublicUserResponse{
+		ID:    user.ID,
+		Login: user.Login,
+		Name:  user.Name,
+	}
+}
+
+func ToSelfUser(user *model.User) dto.SelfUserResponse {
+	return dto.SelfUserResponse{
+		ID:               user.ID,
+		Login:            user.Login,
+		Name:             user.Name,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerifiedOn != nil,
+		Gender:           user.Gender,
+		Birthday:         user.Birthday,
+		TwoFactorEnabled: user.TOTPEnabled,
+		Roles:            roleNames(user.Roles),
+		CreatedOn:        user.CreatedOn,
+	}
+}
+
+func ToAdminUser(user *model.User) dto.AdminUserResponse {
+	return dto.AdminUserResponse{
+		ID:               user.ID,
+		Login:            user.Login,
+		Name:             user.Name,
+		Email:            user.Email,
+		EmailVerifiedOn:  user.EmailVerifiedOn,
+		Gender:           user.Gender,
+		Birthday:         user.Birthday,
+		Admin:            user.Admin,
+		TwoFactorEnabled: user.TOTPEnabled,
+		Roles:            roleNames(user.Roles),
+		CreatedOn:        user.CreatedOn,
+		CreatedBy:        user.CreatedBy,
+		ModifiedOn:       user.ModifiedOn,
+		ModifiedBy:       user.ModifiedBy,
+		RevokedOn:        user.RevokedOn,
+		RevokedBy:        user.RevokedBy,
+	}
+}
+
+func ToAdminUsers(users []model.User) []dto.AdminUserResponse {
+	views := make([]dto.AdminUserResponse, 0, len(users))
+
+	for i := range users {
+		views = append(views, ToAdminUser(&users[i]))
+	}
+
+	return views
+}
+
+func roleNames(roles []model.Role) []string {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(roles))
+
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+
+	return names
+}
@@ -1,7 +1,8 @@
 package connect
 
 import (
-	"userapi/internal/model"
+	"context"
+	"userapi/internal/migrate"
 
 	"userapi/internal/logger"
 
@@ -10,16 +11,35 @@ import (
 	"gorm.io/gorm"
 )
 
+func Open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+}
+
+// InitDB connects and refuses to go on unless the schema is at the latest
+// migration. The API never migrates by itself; run `migrate up` first.
 func InitDB(dsn string) *gorm.DB {
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := Open(dsn)
 
 	if err != nil {
 		logger.Log.Error("failed to connect to DB", zap.Error(err))
 		panic(err)
 	}
 
-	if err := db.AutoMigrate(&model.User{}); err != nil {
-		logger.Log.Error("Migration error: %v", zap.Error(err))
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Log.Error("failed to get DB handle", zap.Error(err))
+		panic(err)
+	}
+
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		logger.Log.Error("failed to load migrations", zap.Error(err))
+		panic(err)
+	}
+
+	if err := migrator.Check(context.Background()); err != nil {
+		logger.Log.Error("database schema is not up to date", zap.Error(err))
+		panic(err)
 	}
 
 	logger.Log.Info("connected to DB", zap.String("dsn", dsn))
@@ -6,10 +6,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// AdminUpdateRequest is the admin update body. Like UpdateRequest, the target
+// user comes from the route, not from the body.
 type AdminUpdateRequest struct {
-	ID       uuid.UUID `gorm:"type:char(36);primaryKey"`
+	ID       uuid.UUID `json:"-"`
 	Login    string    `gorm:"unique;not null" validate:"required,alphanum,min=3,max=20"`
-	Password string    `json:"password" validate:"required,min=8,max=20"`
+	Password string    `json:"password"`
 	Name     string    `json:"name" validate:"required"`
 	Gender   int       `json:"gender" validate:"oneof=0 1 2"`
 	Admin    bool
@@ -29,3 +31,7 @@ func (r AdminUpdateRequest) ToUserModel() (model.User, error) {
 func (r AdminUpdateRequest) GetLogin() string {
 	return r.Login
 }
+
+func (r AdminUpdateRequest) GetID() uuid.UUID {
+	return r.ID
+}
@@ -12,9 +12,12 @@ type AdminRegisterRequest struct {
 }
 
 func (r AdminRegisterRequest) ToUserModel() (model.User, error) {
+	email := NormalizeEmail(r.Email)
+
 	return model.User{
 		ID:       uuid.New(),
 		Login:    r.Login,
+		Email:    &email,
 		Password: r.Password,
 		Name:     r.Name,
 		Gender:   r.Gender,
@@ -0,0 +1,96 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"userapi/internal/errors"
+)
+
+// UserPatch is an RFC 7396 merge patch for a user. A nil field was absent from
+// the patch and stays unchanged. Only birthday is nullable: null clears it.
+type UserPatch struct {
+	Login         *string    `json:"login" validate:"omitempty,alphanum,min=3,max=20"`
+	Password      *string    `json:"password"`
+	Name          *string    `json:"name" validate:"omitempty,min=1"`
+	Gender        *int       `json:"gender" validate:"omitempty,oneof=0 1 2"`
+	Birthday      *time.Time `json:"birthday"`
+	ClearBirthday bool       `json:"-"`
+	Admin         *bool      `json:"admin"`
+}
+
+// PasswordChangeHint rejects a password in a self-service profile update;
+// that path would skip the current-password check and the password policy.
+const PasswordChangeHint = "password cannot be changed here, use POST /users/me/password"
+
+// ParseUserPatch decodes a merge patch document. allowAdmin controls whether
+// the privileged admin and password members are accepted.
+func ParseUserPatch(body []byte, allowAdmin bool) (*UserPatch, error) {
+	var members map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, err
+	}
+
+	patch := &UserPatch{}
+	fields := map[string]string{}
+
+	for name, raw := range members {
+		isNull := string(raw) == "null"
+
+		var target interface{}
+
+		switch name {
+		case "login":
+			target = &patch.Login
+		case "password":
+			if !allowAdmin {
+				fields[name] = PasswordChangeHint
+				continue
+			}
+
+			target = &patch.Password
+		case "name":
+			target = &patch.Name
+		case "gender":
+			target = &patch.Gender
+		case "birthday":
+			if isNull {
+				patch.ClearBirthday = true
+				continue
+			}
+
+			target = &patch.Birthday
+		case "admin":
+			if !allowAdmin {
+				fields[name] = fmt.Sprintf("%s cannot be changed", name)
+				continue
+			}
+
+			target = &patch.Admin
+		default:
+			fields[name] = fmt.Sprintf("%s is not a patchable field", name)
+			continue
+		}
+
+		if isNull {
+			fields[name] = fmt.Sprintf("%s cannot be removed", name)
+			continue
+		}
+
+		if err := json.Unmarshal(raw, target); err != nil {
+			fields[name] = fmt.Sprintf("%s has an invalid type", name)
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, &errors.ValidationError{Fields: fields}
+	}
+
+	return patch, nil
+}
+
+func (p *UserPatch) IsEmpty() bool {
+	return p.Login == nil && p.Password == nil && p.Name == nil && p.Gender == nil &&
+		p.Birthday == nil && !p.ClearBirthday && p.Admin == nil
+}
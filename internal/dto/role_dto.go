@@ -0,0 +1,15 @@
+package dto
+
+type RoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=64,lowercase"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
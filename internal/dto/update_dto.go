@@ -6,25 +6,38 @@ import (
 	"github.com/google/uuid"
 )
 
+// UpdateRequest is the self-service update body. The target user comes from the
+// route, never from the body, so ID cannot be bound from JSON. Password is
+// bound only to be rejected, see RejectedFields.
 type UpdateRequest struct {
-	ID       uuid.UUID `gorm:"type:char(36);primaryKey"`
+	ID       uuid.UUID `json:"-"`
 	Login    string    `gorm:"unique;not null" validate:"required,alphanum,min=3,max=20"`
-	Password string    `json:"password" validate:"required,min=8,max=20"`
+	Password *string   `json:"password"`
 	Name     string    `json:"name" validate:"required"`
 	Gender   int       `json:"gender" validate:"oneof=0 1 2"`
 }
 
 func (r UpdateRequest) ToUserModel() (model.User, error) {
 	return model.User{
-		ID:       r.ID,
-		Login:    r.Login,
-		Password: r.Password,
-		Name:     r.Name,
-		Gender:   r.Gender,
-		Admin:    false,
+		ID:     r.ID,
+		Login:  r.Login,
+		Name:   r.Name,
+		Gender: r.Gender,
 	}, nil
 }
 
+func (r UpdateRequest) RejectedFields() map[string]string {
+	if r.Password != nil {
+		return map[string]string{"password": PasswordChangeHint}
+	}
+
+	return nil
+}
+
 func (r UpdateRequest) GetLogin() string {
 	return r.Login
 }
+
+func (r UpdateRequest) GetID() uuid.UUID {
+	return r.ID
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"strings"
 	"time"
 	"userapi/internal/model"
 
@@ -9,16 +10,20 @@ import (
 
 type RegisterRequest struct {
 	Login    string     `gorm:"unique;not null" validate:"required,alphanum,min=3,max=20"`
-	Password string     `json:"password" validate:"required,min=8,max=20"`
+	Email    string     `json:"email" validate:"required,email,max=255"`
+	Password string     `json:"password" validate:"required"`
 	Name     string     `json:"name" validate:"required"`
 	Gender   int        `json:"gender" validate:"oneof=0 1 2"`
 	Birthday *time.Time `json:"birthday,omitempty"`
 }
 
 func (r RegisterRequest) ToUserModel() (model.User, error) {
+	email := NormalizeEmail(r.Email)
+
 	return model.User{
 		ID:       uuid.New(),
 		Login:    r.Login,
+		Email:    &email,
 		Password: r.Password,
 		Name:     r.Name,
 		Gender:   r.Gender,
@@ -29,3 +34,11 @@ func (r RegisterRequest) ToUserModel() (model.User, error) {
 func (r RegisterRequest) GetLogin() string {
 	return r.Login
 }
+
+func (r RegisterRequest) GetEmail() string {
+	return NormalizeEmail(r.Email)
+}
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
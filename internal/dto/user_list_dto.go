@@ -0,0 +1,45 @@
+package dto
+
+import "time"
+
+// UserListQuery is bound from the query string of GET /admin/users.
+// Sort is a whitelisted field, prefixed with "-" for descending order.
+type UserListQuery struct {
+	Limit       int        `form:"limit" json:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor      string     `form:"cursor" json:"cursor"`
+	Sort        string     `form:"sort" json:"sort" validate:"omitempty,oneof=created_on -created_on modified_on -modified_on login -login name -name"`
+	Login       string     `form:"login" json:"login" validate:"omitempty,max=20"`
+	Name        string     `form:"name" json:"name" validate:"omitempty,max=100"`
+	Gender      *int       `form:"gender" json:"gender" validate:"omitempty,oneof=0 1 2"`
+	Admin       *bool      `form:"admin" json:"admin"`
+	CreatedFrom *time.Time `form:"created_from" json:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time `form:"created_to" json:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Revoked     string     `form:"revoked" json:"revoked" validate:"omitempty,oneof=false true all"`
+}
+
+const (
+	DefaultUserListLimit = 20
+	DefaultUserListSort  = "created_on"
+)
+
+// Normalize fills in the defaults so equal requests produce equal queries.
+func (q *UserListQuery) Normalize() {
+	if q.Limit == 0 {
+		q.Limit = DefaultUserListLimit
+	}
+
+	if q.Sort == "" {
+		q.Sort = DefaultUserListSort
+	}
+
+	if q.Revoked == "" {
+		q.Revoked = "false"
+	}
+}
+
+type UserSearchQuery struct {
+	Q     string `form:"q" validate:"required,min=2,max=100"`
+	Limit int    `form:"limit" validate:"omitempty,min=1,max=50"`
+}
+
+const DefaultUserSearchLimit = 20
@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PublicUserResponse is what any signed-in user may see about another user.
+type PublicUserResponse struct {
+	ID    uuid.UUID `json:"id"`
+	Login string    `json:"login"`
+	Name  string    `json:"name"`
+}
+
+// SelfUserResponse is the caller's own account.
+type SelfUserResponse struct {
+	ID               uuid.UUID  `json:"id"`
+	Login            string     `json:"login"`
+	Name             string     `json:"name"`
+	Email            *string    `json:"email,omitempty"`
+	EmailVerified    bool       `json:"email_verified"`
+	Gender           int        `json:"gender"`
+	Birthday         *time.Time `json:"birthday,omitempty"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	Roles            []string   `json:"roles,omitempty"`
+	CreatedOn        time.Time  `json:"created_on"`
+}
+
+// AdminUserResponse adds the audit trail for user management.
+type AdminUserResponse struct {
+	ID               uuid.UUID  `json:"id"`
+	Login            string     `json:"login"`
+	Name             string     `json:"name"`
+	Email            *string    `json:"email,omitempty"`
+	EmailVerifiedOn  *time.Time `json:"email_verified_on,omitempty"`
+	Gender           int        `json:"gender"`
+	Birthday         *time.Time `json:"birthday,omitempty"`
+	Admin            bool       `json:"admin"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	Roles            []string   `json:"roles,omitempty"`
+	CreatedOn        time.Time  `json:"created_on"`
+	CreatedBy        string     `json:"created_by"`
+	ModifiedOn       time.Time  `json:"modified_on"`
+	ModifiedBy       string     `json:"modified_by"`
+	RevokedOn        *time.Time `json:"revoked_on,omitempty"`
+	RevokedBy        *string    `json:"revoked_by,omitempty"`
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+	"userapi/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeysHandler struct {
+	keys *service.KeySet
+}
+
+func NewKeysHandler(keys *service.KeySet) *KeysHandler {
+	return &KeysHandler{keys: keys}
+}
+
+// JWKS is served as a bare RFC 7517 document, not wrapped in Response,
+// so standard JWT libraries can consume it directly.
+func (h *KeysHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -2,6 +2,7 @@ package handler
 
 const (
 	ErrInvalidJSON        = "invalid JSON"
+	ErrInvalidQuery       = "invalid query parameters"
 	ErrConversionFailed   = "conversion failed"
 	ErrRegistrationFailed = "registration failed"
 	ErrLoginFailed        = "invalid login or password"
@@ -13,10 +14,57 @@ const (
 	MsgUserUpdated        = "user updated"
 	MsgUserAuthorize      = "authorization successful"
 	ErrUUID               = "invalid UUID"
+	ErrRefreshFailed      = "invalid or expired refresh token"
+	MsgTokenRefreshed     = "token refreshed"
+	ErrPatchMediaType     = "patch requires application/merge-patch+json"
+	ErrPasswordChange     = "password change failed"
+	MsgPasswordChanged    = "password changed, please log in again"
+	ErrPasswordReset      = "password reset failed"
+	MsgPasswordResetSent  = "if the account exists, a reset link has been sent"
+	MsgPasswordReset      = "password reset, please log in"
+	ErrEmailVerify        = "invalid or expired verification token"
+	MsgEmailVerified      = "email verified"
+	MsgVerificationSent   = "verification email sent if the address is not verified yet"
+	Err2FAFailed          = "two-factor authentication failed"
+	Msg2FARequired        = "two-factor authentication required"
+	Msg2FAEnrollRequired  = "two-factor enrollment required before admin access"
+	Msg2FAEnabled         = "two-factor authentication enabled"
+	Msg2FADisabled        = "two-factor authentication disabled"
+	ErrTooManyAttempts    = "too many failed login attempts, try again later"
+	ErrUnlockFailed       = "unlock failed"
+	MsgUserUnlocked       = "user unlocked"
+	ErrRoleFailed         = "role operation failed"
+	MsgRoleCreated        = "role created"
+	MsgRoleUpdated        = "role updated"
+	MsgRoleDeleted        = "role deleted"
+	MsgRoleAssigned       = "role assigned"
+	MsgRoleRemoved        = "role removed"
+	ErrSessionFailed      = "session operation failed"
+	MsgSessionRevoked     = "session revoked"
+	MsgSessionsRevoked    = "all sessions revoked"
+	ErrRevokeFailed       = "revoke failed"
+	ErrRevokeSelf         = "you cannot revoke your own account"
+	ErrRestoreFailed      = "restore failed"
+	MsgUserRevoked        = "user revoked"
+	MsgUserRestored       = "user restored"
+	ErrLogoutFailed       = "logout failed"
+	MsgLoggedOut          = "logged out"
+	ErrSearchFailed       = "search failed"
+	ErrUserLookup         = "failed to get user"
 
-	LogRegisterFail  = "register: service failed"
-	LogValidationErr = "validation failed"
-	LogUpdateFail    = "update: service failed"
-	LogGetAddFail    = "failed to get all users"
-	LogGetByLogin    = "failed to get user by login"
+	LogRegisterFail    = "register: service failed"
+	LogValidationErr   = "validation failed"
+	LogUpdateFail      = "update: service failed"
+	LogGetAddFail      = "failed to get all users"
+	LogGetByLogin      = "failed to get user by login"
+	LogRefreshFail     = "refresh: service failed"
+	Log2FAFail         = "2fa: service failed"
+	LogLoginGuardFail  = "login guard failed"
+	LogRoleFail        = "role: service failed"
+	LogPasswordFail    = "password: service failed"
+	LogEmailVerifyFail = "verify email: service failed"
+	LogSessionFail     = "session: service failed"
+	LogRevokeFail      = "revoke: service failed"
+	LogLogoutFail      = "token revocation failed"
+	LogSearchFail      = "search: service failed"
 )
@@ -12,6 +12,19 @@ func JSONOK(c *gin.Context, data interface{}) {
 	c.JSON(200, Response{Data: data})
 }
 
+// PageMeta describes a keyset-paginated list; pass NextCursor back as
+// ?cursor= to get the following page.
+type PageMeta struct {
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+func JSONOKWithMeta(c *gin.Context, data interface{}, meta interface{}) {
+	c.JSON(200, Response{Data: data, Meta: meta})
+}
+
 func JSONCreated(c *gin.Context, data interface{}) {
 	c.JSON(201, Response{Data: data})
 }
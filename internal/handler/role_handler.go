@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"net/http"
+	"userapi/internal/dto"
+	"userapi/internal/logger"
+	"userapi/internal/model"
+	"userapi/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type RoleHandler struct {
+	service   *service.RoleService
+	validator *service.UserValidator
+}
+
+func NewRoleHandler(service *service.RoleService, validator *service.UserValidator) *RoleHandler {
+	return &RoleHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+func (h *RoleHandler) GetAll(c *gin.Context) {
+	roles, err := h.service.GetAll()
+
+	if err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrRoleFailed)
+		return
+	}
+
+	JSONOK(c, roles)
+}
+
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	permissions, err := h.service.GetPermissions()
+
+	if err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrRoleFailed)
+		return
+	}
+
+	JSONOK(c, permissions)
+}
+
+func (h *RoleHandler) Create(c *gin.Context) {
+	var req dto.RoleRequest
+
+	if !h.bind(c, &req) {
+		return
+	}
+
+	role := model.Role{Name: req.Name, Description: req.Description}
+
+	if err := h.service.Create(role, req.Permissions); err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		writeServiceError(c, err, ErrRoleFailed)
+		return
+	}
+
+	JSONCreated(c, gin.H{"message": MsgRoleCreated})
+}
+
+func (h *RoleHandler) SetPermissions(c *gin.Context) {
+	var req dto.RolePermissionsRequest
+
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.service.SetPermissions(c.Param("name"), req.Permissions); err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		writeServiceError(c, err, ErrRoleFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgRoleUpdated})
+}
+
+func (h *RoleHandler) Delete(c *gin.Context) {
+	if err := h.service.Delete(c.Param("name")); err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		writeServiceError(c, err, ErrRoleFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgRoleDeleted})
+}
+
+func (h *RoleHandler) AssignToUser(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req dto.AssignRoleRequest
+
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.service.AssignToUser(c.Request.Context(), id, req.Role); err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		writeServiceError(c, err, ErrRoleFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgRoleAssigned})
+}
+
+func (h *RoleHandler) RemoveFromUser(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveFromUser(c.Request.Context(), id, c.Param("role")); err != nil {
+		logger.WarnError(c, LogRoleFail, err)
+		writeServiceError(c, err, ErrRoleFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgRoleRemoved})
+}
+
+func (h *RoleHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return false
+	}
+
+	if errs := h.validator.Validate(req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"userapi/internal/auth"
+	"userapi/internal/logger"
+	"userapi/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler struct {
+	revoker *service.TokenRevoker
+	clients map[string]string
+}
+
+func NewTokenHandler(revoker *service.TokenRevoker, clients map[string]string) *TokenHandler {
+	return &TokenHandler{revoker: revoker, clients: clients}
+}
+
+// Logout must run after JWTMiddleware; it ends the presented token and its session.
+func (h *TokenHandler) Logout(c *gin.Context) {
+	claims, ok := auth.ClaimsFrom(c)
+	if !ok {
+		JSONErrorMsg(c, http.StatusUnauthorized, ErrLogoutFailed)
+		return
+	}
+
+	if err := h.revoker.Logout(c.Request.Context(), claims); err != nil {
+		logger.WarnError(c, LogLogoutFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrLogoutFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgLoggedOut})
+}
+
+// Revoke is the RFC 7009 revocation endpoint for other services. Like JWKS it
+// speaks the RFC's wire format instead of Response: a form-encoded request,
+// an empty 200 and OAuth error objects.
+func (h *TokenHandler) Revoke(c *gin.Context) {
+	if !h.authenticateClient(c) {
+		c.Header("WWW-Authenticate", `Basic realm="token revocation"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	if err := h.revoker.Revoke(c.Request.Context(), token, c.PostForm("token_type_hint")); err != nil {
+		logger.WarnError(c, LogLogoutFail, err)
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *TokenHandler) authenticateClient(c *gin.Context) bool {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	expected, known := h.clients[id]
+	if !known {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
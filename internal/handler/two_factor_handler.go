@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+	"userapi/internal/auth"
+	"userapi/internal/dto"
+	"userapi/internal/logger"
+	"userapi/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type TwoFactorHandler struct {
+	service   *service.TwoFactorService
+	validator *service.UserValidator
+}
+
+func NewTwoFactorHandler(service *service.TwoFactorService, validator *service.UserValidator) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		service:   service,
+		validator: validator,
+	}
+}
+
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.service.Enroll(id)
+
+	if err != nil {
+		logger.WarnError(c, Log2FAFail, err)
+		writeServiceError(c, err, Err2FAFailed)
+		return
+	}
+
+	JSONOK(c, enrollment)
+}
+
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.TOTPCodeRequest
+
+	if !h.bind(c, &req) {
+		return
+	}
+
+	if err := h.service.Confirm(c.Request.Context(), id, req.Code); err != nil {
+		logger.WarnError(c, Log2FAFail, err)
+		writeServiceError(c, err, Err2FAFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": Msg2FAEnabled})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.DisableTOTPRequest
+
+	if !h.bind(c, &req) {
+		return
+	}
+
+	isAdmin := auth.HasRole(c, auth.RoleAdmin)
+
+	if err := h.service.Disable(c.Request.Context(), id, req.Password, req.Code, isAdmin); err != nil {
+		logger.WarnError(c, Log2FAFail, err)
+		writeServiceError(c, err, Err2FAFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": Msg2FADisabled})
+}
+
+func (h *TwoFactorHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return false
+	}
+
+	if errs := h.validator.Validate(req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return false
+	}
+
+	return true
+}
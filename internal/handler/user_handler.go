@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"userapi/internal/config"
+	"userapi/internal/auth"
+	"userapi/internal/contract"
 	"userapi/internal/dto"
+	customErrors "userapi/internal/errors"
 	"userapi/internal/logger"
 	"userapi/internal/model"
 	"userapi/internal/service"
@@ -11,6 +15,7 @@ import (
 	"time"
 
 	"userapi/internal/kafka"
+	"userapi/internal/lifecycle"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,21 +25,24 @@ import (
 type UserHandler struct {
 	service       *service.UserService
 	validator     *service.UserValidator
-	redisService  *service.RedisService
 	kafkaProducer *kafka.KafkaProducer
+	loginGuard    *service.LoginGuard
+	tasks         *lifecycle.Tasks
 }
 
 func NewUserHandler(
 	service *service.UserService,
 	validator *service.UserValidator,
-	redisService *service.RedisService,
 	kafkaProducer *kafka.KafkaProducer,
+	loginGuard *service.LoginGuard,
+	tasks *lifecycle.Tasks,
 ) *UserHandler {
 	return &UserHandler{
 		service:       service,
 		validator:     validator,
-		redisService:  redisService,
 		kafkaProducer: kafkaProducer,
+		loginGuard:    loginGuard,
+		tasks:         tasks,
 	}
 }
 
@@ -53,17 +61,146 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.Login(req.Login, req.Password)
+	ctx := kafka.WithActor(c.Request.Context(), req.Login)
+	c.Request = c.Request.WithContext(ctx)
+	ip := c.ClientIP()
+
+	if h.loginLocked(c, req.Login, ip) {
+		return
+	}
+
+	result, err := h.service.Login(ctx, req.Login, req.Password, clientInfo(c))
 
 	if err != nil {
 		logger.WarnError(c, ErrLoginFailed, err)
+
+		var forbidden *customErrors.ForbiddenError
+		if errors.As(err, &forbidden) {
+			JSONErrorMsg(c, http.StatusForbidden, forbidden.Reason)
+			return
+		}
+
+		var (
+			unauthorized *customErrors.UnauthorizedError
+			notFound     *customErrors.NotFoundError
+		)
+
+		isBadCredentials := errors.As(err, &unauthorized) || errors.As(err, &notFound)
+
+		if isBadCredentials && h.recordLoginFailure(c, req.Login, ip) {
+			return
+		}
+
 		JSONErrorMsg(c, http.StatusUnauthorized, ErrLoginFailed)
 		return
 	}
 
-	c.Header("Authorization", "Bearer "+token)
+	// A correct password alone is not a successful login for 2FA users; the
+	// failure count is reset only once LoginTOTP has issued tokens.
+	if result.ChallengeToken != "" {
+		JSONOK(c, gin.H{
+			"message":             Msg2FARequired,
+			"two_factor_required": true,
+			"challenge_token":     result.ChallengeToken,
+		})
+		return
+	}
 
-	JSONOK(c, gin.H{"message": MsgUserAuthorize})
+	if err := h.loginGuard.RecordSuccess(ctx, req.Login); err != nil {
+		logger.WarnError(c, LogLoginGuardFail, err)
+	}
+
+	message := MsgUserAuthorize
+	if result.EnrollmentRequired {
+		message = Msg2FAEnrollRequired
+	}
+
+	writeTokens(c, result.Tokens, message)
+}
+
+func (h *UserHandler) LoginTOTP(c *gin.Context) {
+	var req dto.LoginTOTPRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return
+	}
+
+	if errs := h.validator.Validate(&req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	login, err := h.service.ChallengeLogin(c.Request.Context(), req.ChallengeToken)
+
+	if err != nil {
+		logger.WarnError(c, ErrLoginFailed, err)
+		writeServiceError(c, err, Err2FAFailed)
+		return
+	}
+
+	ctx := kafka.WithActor(c.Request.Context(), login)
+	c.Request = c.Request.WithContext(ctx)
+	ip := c.ClientIP()
+
+	if h.loginLocked(c, login, ip) {
+		return
+	}
+
+	tokens, err := h.service.LoginTOTP(ctx, req.ChallengeToken, req.Code, clientInfo(c))
+
+	if err != nil {
+		logger.WarnError(c, ErrLoginFailed, err)
+
+		var unauthorized *customErrors.UnauthorizedError
+		if errors.As(err, &unauthorized) && h.recordLoginFailure(c, login, ip) {
+			return
+		}
+
+		writeServiceError(c, err, Err2FAFailed)
+		return
+	}
+
+	if err := h.loginGuard.RecordSuccess(ctx, login); err != nil {
+		logger.WarnError(c, LogLoginGuardFail, err)
+	}
+
+	writeTokens(c, tokens, MsgUserAuthorize)
+}
+
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return
+	}
+
+	if errs := h.validator.ValidateRefreshRequest(&req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	tokens, err := h.service.Refresh(c.Request.Context(), req.RefreshToken, clientInfo(c))
+
+	if err != nil {
+		logger.WarnError(c, LogRefreshFail, err)
+
+		var unauthorized *customErrors.UnauthorizedError
+		if errors.As(err, &unauthorized) {
+			JSONErrorMsg(c, http.StatusUnauthorized, ErrRefreshFailed)
+			return
+		}
+
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrRefreshFailed)
+		return
+	}
+
+	writeTokens(c, tokens, MsgTokenRefreshed)
 }
 
 func (h *UserHandler) RegisterUser(c *gin.Context) {
@@ -73,7 +210,6 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		"self",
 		h.validator,
 		h.service.Register,
-		h.kafkaProducer,
 	)
 }
 
@@ -81,10 +217,11 @@ func (h *UserHandler) RegisterAdmin(c *gin.Context) {
 	HandleRegister(
 		c,
 		&dto.AdminRegisterRequest{},
-		c.GetString("login"),
+		auth.Login(c),
 		h.validator,
-		h.service.Register,
-		h.kafkaProducer,
+		func(ctx context.Context, user model.User) error {
+			return h.service.RegisterAdmin(ctx, user, auth.CanGrantAdmin(c))
+		},
 	)
 }
 
@@ -101,7 +238,7 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(id); err != nil {
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
 		logger.WarnError(c, ErrDelete, err)
 
 		JSONErrorMsg(c, http.StatusInternalServerError, ErrDelete)
@@ -113,66 +250,471 @@ func (h *UserHandler) Delete(c *gin.Context) {
 }
 
 func (h *UserHandler) Update(c *gin.Context) {
+	target := auth.TargetUserID(c)
+
 	HandleUpdate(
 		c,
-		&dto.AdminUpdateRequest{},
+		&dto.AdminUpdateRequest{ID: target},
 		h.validator,
-		h.service.Update,
+		func(ctx context.Context, user model.User) error {
+			user.ID = target
+			return h.service.Update(ctx, user, auth.CanGrantAdmin(c))
+		},
 	)
 }
 
 func (h *UserHandler) GetAll(c *gin.Context) {
-	var users []model.User
-	ctx := c.Request.Context()
+	var query dto.UserListQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logger.WarnError(c, ErrInvalidQuery, err)
+
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidQuery)
+
+		return
+	}
+
+	if errs := h.validator.Validate(query); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+
+		JSONError(c, http.StatusBadRequest, errs)
 
-	users, err := h.service.GetAll(ctx)
+		return
+	}
+
+	page, err := h.service.GetAll(c.Request.Context(), query)
 	if err != nil {
 		logger.WarnError(c, LogGetAddFail, err)
 
-		JSONErrorMsg(c, http.StatusInternalServerError, ErrConversionFailed)
+		writeServiceError(c, err, ErrConversionFailed)
+
+		return
+	}
+
+	query.Normalize()
+
+	JSONOKWithMeta(c, page.Users, PageMeta{
+		Limit:      query.Limit,
+		Sort:       query.Sort,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	})
+}
+
+func (h *UserHandler) Search(c *gin.Context) {
+	var query dto.UserSearchQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		logger.WarnError(c, ErrInvalidQuery, err)
+
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidQuery)
+
+		return
+	}
+
+	if errs := h.validator.Validate(query); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+
+		JSONError(c, http.StatusBadRequest, errs)
 
 		return
 	}
 
-	JSONOK(c, users)
+	users, err := h.service.Search(c.Request.Context(), query)
+	if err != nil {
+		logger.WarnError(c, LogSearchFail, err)
+
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrSearchFailed)
+
+		return
+	}
+
+	JSONOK(c, contract.ToAdminUsers(users))
 }
 
 func (h *UserHandler) GetByLogin(c *gin.Context) {
 	login := c.Param("login")
 
-	user, err := h.service.GetByLogin(login)
+	user, err := h.service.GetByLogin(login, includeRevoked(c))
 
 	if err != nil {
 		logger.WarnError(c, LogGetByLogin, err)
 
-		JSONErrorMsg(c, http.StatusInternalServerError, err.Error())
+		writeServiceError(c, err, ErrUserLookup)
 
 		return
 	}
 
-	JSONOK(c, user)
+	JSONOK(c, contract.ToAdminUser(user))
 }
 
-func (h *UserHandler) Logout(c *gin.Context) {
-	jti := c.GetString("jti")
+func (h *UserHandler) GetMe(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetById(id)
+
+	if err != nil {
+		logger.WarnError(c, LogGetByLogin, err)
+		writeServiceError(c, err, ErrUserLookup)
+		return
+	}
+
+	JSONOK(c, contract.ToSelfUser(user))
+}
 
-	exp := time.Now().Add(config.GetJwtExpiration())
-	ttl := time.Until(exp)
+// GetProfile is the public profile any signed-in user may look up.
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	user, err := h.service.GetByLogin(c.Param("login"), false)
 
-	err := h.redisService.SetToBlacklist(c.Request.Context(), jti, ttl)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "logout failed"})
+		logger.WarnError(c, LogGetByLogin, err)
+		writeServiceError(c, err, ErrUserLookup)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	JSONOK(c, contract.ToPublicUser(user))
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	target := auth.TargetUserID(c)
+
 	HandleUpdate(
 		c,
-		&dto.UpdateRequest{},
+		&dto.UpdateRequest{ID: target},
 		h.validator,
-		h.service.Update,
+		func(ctx context.Context, user model.User) error {
+			user.ID = target
+			return h.service.UpdateProfile(ctx, user)
+		},
 	)
 }
+
+func (h *UserHandler) PatchProfile(c *gin.Context) {
+	HandlePatch(c, false, h.validator, func(ctx context.Context, id uuid.UUID, patch dto.UserPatch, modifiedBy string) error {
+		return h.service.Patch(ctx, id, patch, modifiedBy, false)
+	})
+}
+
+func (h *UserHandler) Patch(c *gin.Context) {
+	canGrantAdmin := auth.CanGrantAdmin(c)
+
+	HandlePatch(c, true, h.validator, func(ctx context.Context, id uuid.UUID, patch dto.UserPatch, modifiedBy string) error {
+		return h.service.Patch(ctx, id, patch, modifiedBy, canGrantAdmin)
+	})
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req dto.ChangePasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return
+	}
+
+	if errs := h.validator.Validate(&req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.ChangePassword(c.Request.Context(), id, req.CurrentPassword, req.NewPassword); err != nil {
+		logger.WarnError(c, LogPasswordFail, err)
+		writeServiceError(c, err, ErrPasswordChange)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgPasswordChanged})
+}
+
+// ForgotPassword always answers 202 and does the work in the background, so neither
+// the response nor its timing reveals whether the account exists.
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return
+	}
+
+	if errs := h.validator.Validate(&req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	h.tasks.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := h.service.RequestPasswordReset(ctx, dto.NormalizeEmail(req.Email)); err != nil {
+			logger.Log.Warn(LogPasswordFail, zap.Error(err))
+		}
+	})
+
+	c.JSON(http.StatusAccepted, Response{Data: gin.H{"message": MsgPasswordResetSent}})
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+		return
+	}
+
+	if errs := h.validator.Validate(&req); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+		JSONError(c, http.StatusBadRequest, errs)
+		return
+	}
+
+	if err := h.service.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		logger.WarnError(c, LogPasswordFail, err)
+		writeServiceError(c, err, ErrPasswordReset)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgPasswordReset})
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+
+	if token == "" {
+		JSONErrorMsg(c, http.StatusBadRequest, ErrEmailVerify)
+		return
+	}
+
+	if _, err := h.service.VerifyEmail(c.Request.Context(), token); err != nil {
+		logger.WarnError(c, LogEmailVerifyFail, err)
+
+		var unauthorized *customErrors.UnauthorizedError
+		if errors.As(err, &unauthorized) {
+			JSONErrorMsg(c, http.StatusBadRequest, ErrEmailVerify)
+			return
+		}
+
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrEmailVerify)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgEmailVerified})
+}
+
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetById(id)
+	if err != nil {
+		logger.WarnError(c, LogEmailVerifyFail, err)
+		writeServiceError(c, err, ErrEmailVerify)
+		return
+	}
+
+	if err := h.service.SendEmailVerification(c.Request.Context(), user); err != nil {
+		logger.WarnError(c, LogEmailVerifyFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrEmailVerify)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, Response{Data: gin.H{"message": MsgVerificationSent}})
+}
+
+// loginLocked answers 429 while the login or the IP is locked out. Both login
+// steps go through it, so a known password does not open unlimited TOTP guesses.
+// It reports whether a response has been written.
+func (h *UserHandler) loginLocked(c *gin.Context, login, ip string) bool {
+	retryAfter, err := h.loginGuard.Check(c.Request.Context(), login, ip)
+
+	if err != nil {
+		logger.WarnError(c, LogLoginGuardFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrLoginFailed)
+		return true
+	}
+
+	if retryAfter > 0 {
+		writeTooManyAttempts(c, retryAfter)
+		return true
+	}
+
+	return false
+}
+
+// recordLoginFailure counts a failed attempt and answers 429 when it caused a lock.
+// It reports whether a response has been written.
+func (h *UserHandler) recordLoginFailure(c *gin.Context, login, ip string) bool {
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	h.sendEvent(ctx, kafka.UserLoginFailedEvent{Login: login, IP: ip})
+
+	accountLock, retryAfter, err := h.loginGuard.RecordFailure(c.Request.Context(), login, ip)
+
+	if err != nil {
+		logger.WarnError(c, LogLoginGuardFail, err)
+		return false
+	}
+
+	if accountLock > 0 {
+		event := kafka.UserLockedEvent{
+			Login:       login,
+			IP:          ip,
+			LockedUntil: time.Now().Add(accountLock).Format(time.RFC3339),
+		}
+
+		h.sendEvent(ctx, event)
+	}
+
+	if retryAfter > 0 {
+		writeTooManyAttempts(c, retryAfter)
+		return true
+	}
+
+	return false
+}
+
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetById(id)
+
+	if err != nil {
+		logger.WarnError(c, LogLoginGuardFail, err)
+		writeServiceError(c, err, ErrUnlockFailed)
+		return
+	}
+
+	if err := h.loginGuard.Unlock(c.Request.Context(), user.Login); err != nil {
+		logger.WarnError(c, LogLoginGuardFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrUnlockFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgUserUnlocked})
+}
+
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.ListSessions(c.Request.Context(), id)
+
+	if err != nil {
+		logger.WarnError(c, LogSessionFail, err)
+		JSONErrorMsg(c, http.StatusInternalServerError, ErrSessionFailed)
+		return
+	}
+
+	current := auth.SessionID(c)
+	response := make([]dto.SessionResponse, 0, len(sessions))
+
+	for _, s := range sessions {
+		response = append(response, dto.SessionResponse{
+			ID:        s.ID,
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			CreatedAt: s.CreatedAt,
+			LastUsed:  s.IssuedAt,
+			ExpiresAt: s.ExpiresAt,
+			Current:   s.ID == current,
+		})
+	}
+
+	JSONOK(c, response)
+}
+
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeSession(c.Request.Context(), id, c.Param("id")); err != nil {
+		logger.WarnError(c, LogSessionFail, err)
+		writeServiceError(c, err, ErrSessionFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgSessionRevoked})
+}
+
+// RevokeAllSessions is the admin "sign out everywhere" for any user.
+func (h *UserHandler) RevokeAllSessions(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAllSessions(c.Request.Context(), id); err != nil {
+		logger.WarnError(c, LogSessionFail, err)
+		writeServiceError(c, err, ErrSessionFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgSessionsRevoked})
+}
+
+func (h *UserHandler) Revoke(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if id.String() == auth.UserID(c) {
+		JSONErrorMsg(c, http.StatusForbidden, ErrRevokeSelf)
+		return
+	}
+
+	if _, _, err := h.service.Revoke(c.Request.Context(), id, auth.Login(c)); err != nil {
+		logger.WarnError(c, LogRevokeFail, err)
+		writeServiceError(c, err, ErrRevokeFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgUserRevoked})
+}
+
+func (h *UserHandler) Restore(c *gin.Context) {
+	id, ok := parseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, _, err := h.service.Restore(c.Request.Context(), id, auth.Login(c)); err != nil {
+		logger.WarnError(c, LogRevokeFail, err)
+		writeServiceError(c, err, ErrRestoreFailed)
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgUserRestored})
+}
+
+// sendEvent publishes outside the request; shutdown waits for it through tasks.
+func (h *UserHandler) sendEvent(ctx context.Context, event kafka.Event) {
+	h.tasks.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		if err := h.kafkaProducer.SendMessage(ctx, event); err != nil {
+			logger.Log.Warn("failed to publish event", zap.String("type", event.EventType()), zap.Error(err))
+		}
+	})
+}
@@ -1,15 +1,22 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
+	"userapi/internal/auth"
 	"userapi/internal/contract"
-	"userapi/internal/kafka"
+	"userapi/internal/dto"
+	customErrors "userapi/internal/errors"
 	"userapi/internal/logger"
 	"userapi/internal/model"
 	"userapi/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -50,8 +57,7 @@ func HandleRegister(
 	dtoObj contract.IUserModelConvert,
 	createdBy string,
 	validator *service.UserValidator,
-	registerFunc func(model.User) error,
-	kafkaProducer *kafka.KafkaProducer,
+	registerFunc func(context.Context, model.User) error,
 ) {
 	user, ok := BindValidateConvert(c, dtoObj, validator)
 
@@ -61,22 +67,14 @@ func HandleRegister(
 
 	user.CreatedBy = createdBy
 
-	if err := registerFunc(user); err != nil {
+	if err := registerFunc(c.Request.Context(), user); err != nil {
 		logger.WarnError(c, LogValidationErr, err)
 
-		JSONErrorMsg(c, http.StatusInternalServerError, ErrRegistrationFailed)
+		writeServiceError(c, err, ErrRegistrationFailed)
 
 		return
 	}
 
-	event := kafka.UserRegisteredEvent{
-		UserID: user.ID.String(),
-		Login:  user.Login,
-		Time:   time.Now().Format(time.RFC3339),
-	}
-
-	go kafkaProducer.SendMessage(c.Request.Context(), event)
-
 	JSONCreated(c, gin.H{"message": MsgUserRegistered})
 }
 
@@ -84,7 +82,7 @@ func HandleUpdate(
 	c *gin.Context,
 	dtoObj contract.IUserModelConvert,
 	validator *service.UserValidator,
-	updateFunc func(model.User) error,
+	updateFunc func(context.Context, model.User) error,
 ) {
 	user, ok := BindValidateConvert(c, dtoObj, validator)
 
@@ -92,15 +90,158 @@ func HandleUpdate(
 		return
 	}
 
-	user.ModifiedBy = c.GetString("login")
+	user.ModifiedBy = auth.Login(c)
 
-	if err := updateFunc(user); err != nil {
+	if err := updateFunc(c.Request.Context(), user); err != nil {
 		logger.WarnError(c, LogUpdateFail, err)
 
-		JSONErrorMsg(c, http.StatusInternalServerError, ErrUpdateFailed)
+		writeServiceError(c, err, ErrUpdateFailed)
 
 		return
 	}
 
 	JSONOK(c, gin.H{"message": MsgUserUpdated})
 }
+
+func writeTokens(c *gin.Context, tokens *service.TokenPair, message string) {
+	c.Header("Authorization", "Bearer "+tokens.AccessToken)
+
+	JSONOK(c, gin.H{
+		"message":       message,
+		"refresh_token": tokens.RefreshToken,
+	})
+}
+
+// HandlePatch applies an RFC 7396 merge patch to the user resolved by the
+// route's authorization policy (see auth.TargetUserID).
+func HandlePatch(
+	c *gin.Context,
+	allowAdmin bool,
+	validator *service.UserValidator,
+	patchFunc func(context.Context, uuid.UUID, dto.UserPatch, string) error,
+) {
+	if ct := c.ContentType(); ct != "application/merge-patch+json" && ct != "application/json" {
+		JSONErrorMsg(c, http.StatusUnsupportedMediaType, ErrPatchMediaType)
+
+		return
+	}
+
+	body, err := c.GetRawData()
+
+	if err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+
+		return
+	}
+
+	patch, err := dto.ParseUserPatch(body, allowAdmin)
+
+	if err != nil {
+		logger.WarnError(c, ErrInvalidJSON, err)
+
+		var validation *customErrors.ValidationError
+		if errors.As(err, &validation) {
+			JSONError(c, http.StatusBadRequest, validation.Fields)
+			return
+		}
+
+		JSONErrorMsg(c, http.StatusBadRequest, ErrInvalidJSON)
+
+		return
+	}
+
+	if errs := validator.ValidatePatch(patch); len(errs) > 0 {
+		logger.WarnFields(c, LogValidationErr, zap.Any("errors", errs))
+
+		JSONError(c, http.StatusBadRequest, errs)
+
+		return
+	}
+
+	if err := patchFunc(c.Request.Context(), auth.TargetUserID(c), *patch, auth.Login(c)); err != nil {
+		logger.WarnError(c, LogUpdateFail, err)
+
+		writeServiceError(c, err, ErrUpdateFailed)
+
+		return
+	}
+
+	JSONOK(c, gin.H{"message": MsgUserUpdated})
+}
+
+// writeServiceError maps the custom service errors to HTTP statuses.
+// Anything unexpected becomes a 500 with the given message.
+func writeServiceError(c *gin.Context, err error, msg string) {
+	var (
+		notFound     *customErrors.NotFoundError
+		conflict     *customErrors.ConflictError
+		validation   *customErrors.ValidationError
+		unauthorized *customErrors.UnauthorizedError
+		forbidden    *customErrors.ForbiddenError
+	)
+
+	switch {
+	case errors.As(err, &validation):
+		JSONError(c, http.StatusBadRequest, validation.Fields)
+	case errors.As(err, &notFound):
+		JSONErrorMsg(c, http.StatusNotFound, notFound.Error())
+	case errors.As(err, &conflict):
+		JSONErrorMsg(c, http.StatusConflict, conflict.Error())
+	case errors.As(err, &unauthorized):
+		JSONErrorMsg(c, http.StatusUnauthorized, msg)
+	case errors.As(err, &forbidden):
+		JSONErrorMsg(c, http.StatusForbidden, forbidden.Reason)
+	default:
+		JSONErrorMsg(c, http.StatusInternalServerError, msg)
+	}
+}
+
+func parseUUIDParam(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+
+	if err != nil {
+		logger.WarnError(c, ErrUUID, err)
+		JSONErrorMsg(c, http.StatusBadRequest, ErrUUID)
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(auth.UserID(c))
+
+	if err != nil {
+		logger.WarnError(c, ErrUUID, err)
+		JSONErrorMsg(c, http.StatusUnauthorized, ErrUUID)
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func writeTooManyAttempts(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	JSONErrorMsg(c, http.StatusTooManyRequests, ErrTooManyAttempts)
+}
+
+// clientInfo identifies the device for the session registry.
+func clientInfo(c *gin.Context) service.ClientInfo {
+	ua := c.Request.UserAgent()
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+
+	return service.ClientInfo{UserAgent: ua, IP: c.ClientIP()}
+}
+
+// includeRevoked reads the admin-only include_revoked query switch.
+func includeRevoked(c *gin.Context) bool {
+	include, _ := strconv.ParseBool(c.Query("include_revoked"))
+
+	return include
+}
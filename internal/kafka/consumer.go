@@ -2,32 +2,175 @@ package kafka
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
+	"userapi/internal/logger"
 
 	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
 )
 
+const commitTimeout = 5 * time.Second
+
+type ConsumerConfig struct {
+	Broker      string
+	Topic       string
+	GroupID     string
+	DLQTopic    string
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// KafkaConsumer hands every message to the handler registered for its event
+// type and commits its offset only once the message is dealt with: handled,
+// skipped because no handler wants it, or parked on the dead-letter topic.
+// A crash in between redelivers the message, so handlers must be idempotent.
 type KafkaConsumer struct {
-	reader *kafka.Reader
+	reader   *kafka.Reader
+	dlq      *kafka.Writer
+	registry *Registry
+	cfg      ConsumerConfig
 }
 
-func NewConsumer(brocker, topic string) *KafkaConsumer {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{brocker},
-		Topic:   topic,
-		GroupID: "user-consumer-group",
+func NewConsumer(cfg ConsumerConfig, registry *Registry) *KafkaConsumer {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{cfg.Broker},
+		Topic:   cfg.Topic,
+		GroupID: cfg.GroupID,
 	})
 
-	return &KafkaConsumer{reader: r}
+	dlq := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Broker),
+		Topic:                  cfg.DLQTopic,
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+	}
+
+	return &KafkaConsumer{reader: reader, dlq: dlq, registry: registry, cfg: cfg}
 }
 
-func (c KafkaConsumer) Start(ctx context.Context) error {
+// Start consumes until ctx is cancelled, which is not reported as an error.
+// Messages of a partition are handled one at a time, so retries hold back
+// later events of the same user instead of reordering them.
+func (c *KafkaConsumer) Start(ctx context.Context) error {
 	for {
-		m, err := c.reader.ReadMessage(ctx)
+		m, err := c.reader.FetchMessage(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		if err := c.process(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		if err := c.commit(ctx, m); err != nil {
 			return err
 		}
+	}
+}
+
+// commit outlives ctx: a message handled just before shutdown is still
+// committed instead of being redelivered.
+func (c *KafkaConsumer) commit(ctx context.Context, m kafka.Message) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+	defer cancel()
+
+	return c.reader.CommitMessages(ctx, m)
+}
+
+// process returns an error only when the message could neither be handled
+// nor dead-lettered; it must then not be committed.
+func (c *KafkaConsumer) process(ctx context.Context, m kafka.Message) error {
+	var envelope Envelope
+
+	if err := json.Unmarshal(m.Value, &envelope); err != nil {
+		return c.deadLetter(ctx, m, fmt.Errorf("decode envelope: %w", err), 0)
+	}
+
+	if envelope.SchemaVersion > SchemaVersion {
+		return c.deadLetter(ctx, m, fmt.Errorf("unsupported schema version %d", envelope.SchemaVersion), 0)
+	}
+
+	handler, ok := c.registry.lookup(envelope.Type)
+	if !ok {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := handler(ctx, envelope)
+		if err == nil {
+			return nil
+		}
+
+		if isPermanent(err) || attempt > c.cfg.MaxRetries {
+			return c.deadLetter(ctx, m, err, attempt)
+		}
+
+		logger.Log.Warn("event handler failed, retrying",
+			zap.String("type", envelope.Type),
+			zap.String("event_id", envelope.EventID),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+	}
+}
+
+func (c *KafkaConsumer) backoff(attempt int) time.Duration {
+	return min(c.cfg.BaseBackoff<<min(attempt-1, 16), c.cfg.MaxBackoff)
+}
 
-		fmt.Printf("🎯 Received message: %s\n", string(m.Value))
+// deadLetter copies the message to the dead-letter topic unchanged, adding
+// headers that say where it came from and why it failed.
+func (c *KafkaConsumer) deadLetter(ctx context.Context, m kafka.Message, cause error, attempts int) error {
+	logger.Log.Error("event dead-lettered",
+		zap.String("topic", m.Topic),
+		zap.Int("partition", m.Partition),
+		zap.Int64("offset", m.Offset),
+		zap.Int("attempts", attempts),
+		zap.Error(cause),
+	)
+
+	headers := append([]kafka.Header{}, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "dlq_error", Value: []byte(cause.Error())},
+		kafka.Header{Key: "dlq_attempts", Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: "dlq_original_topic", Value: []byte(m.Topic)},
+		kafka.Header{Key: "dlq_original_partition", Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: "dlq_original_offset", Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: "dlq_failed_at", Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	err := c.dlq.WriteMessages(ctx, kafka.Message{Key: m.Key, Value: m.Value, Headers: headers})
+	if err != nil {
+		return fmt.Errorf("write to dead-letter topic: %w", err)
 	}
+
+	return nil
+}
+
+func (c *KafkaConsumer) Close() error {
+	dlqErr := c.dlq.Close()
+
+	if err := c.reader.Close(); err != nil {
+		return err
+	}
+
+	return dlqErr
 }
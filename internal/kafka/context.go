@@ -0,0 +1,38 @@
+package kafka
+
+import "context"
+
+const anonymousActor = "anonymous"
+
+type metaKey struct{}
+
+type meta struct {
+	actor   string
+	traceID string
+}
+
+// WithActor records who is acting; events built from the context carry it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	m := metaFrom(ctx)
+	m.actor = actor
+
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+// WithTraceID records the request's trace ID for the events it causes.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	m := metaFrom(ctx)
+	m.traceID = traceID
+
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+func metaFrom(ctx context.Context) meta {
+	m, _ := ctx.Value(metaKey{}).(meta)
+
+	if m.actor == "" {
+		m.actor = anonymousActor
+	}
+
+	return m
+}
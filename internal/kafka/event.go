@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is bumped on incompatible changes to the envelope or to any
+// payload; consumers should reject versions they do not know.
+const SchemaVersion = 1
+
+const (
+	TypeUserRegistered      = "user.registered"
+	TypeUserUpdated         = "user.updated"
+	TypeUserDeleted         = "user.deleted"
+	TypeUserRevoked         = "user.revoked"
+	TypeUserRestored        = "user.restored"
+	TypeUserRoleChanged     = "user.role_changed"
+	TypeUserLoggedIn        = "user.logged_in"
+	TypeUserLoginFailed     = "user.login_failed"
+	TypeUserLocked          = "user.locked"
+	TypeUserPasswordChanged = "user.password_changed"
+	TypeUserEmailVerified   = "user.email_verified"
+)
+
+// Event is a payload of the user event catalogue.
+type Event interface {
+	EventType() string
+	// Key is the Kafka message key. It is the user ID wherever one is
+	// known, so all events of a user land in one partition in order.
+	Key() string
+}
+
+// Envelope is the wire format of every message on the user topic.
+type Envelope struct {
+	EventID       string          `json:"event_id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Actor         string          `json:"actor"`
+	TraceID       string          `json:"trace_id,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// NewEnvelope wraps the event, taking the actor and trace ID from ctx.
+func NewEnvelope(ctx context.Context, event Event) (Envelope, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	meta := metaFrom(ctx)
+
+	return Envelope{
+		EventID:       uuid.New().String(),
+		Type:          event.EventType(),
+		SchemaVersion: SchemaVersion,
+		OccurredAt:    time.Now().UTC(),
+		Actor:         meta.actor,
+		TraceID:       meta.traceID,
+		Data:          data,
+	}, nil
+}
@@ -3,7 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-
+	"errors"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -13,31 +13,98 @@ type KafkaProducer struct {
 	writer *kafka.Writer
 }
 
+// NewProducer hashes message keys to partitions, so messages with the same
+// key keep their order.
 func NewProducer(brokerURL, topic string) *KafkaProducer {
 	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  []string{brokerURL},
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Brokers:      []string{brokerURL},
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
 	})
 
 	return &KafkaProducer{writer: writer}
 }
 
-func (p *KafkaProducer) SendMessage(ctx context.Context, value interface{}) error {
-	bytes, err := json.Marshal(value)
-
+// SendMessage publishes one event directly, outside the outbox. Use it only
+// for events that do not describe a database change.
+func (p *KafkaProducer) SendMessage(ctx context.Context, event Event) error {
+	msg, err := EncodeEvent(ctx, event)
 	if err != nil {
 		return err
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(time.Now().Format(time.RFC3339)),
-		Value: bytes,
+	return p.Publish(ctx, msg)
+}
+
+// EncodeEvent wraps the event in its envelope and keys it by Event.Key.
+func EncodeEvent(ctx context.Context, event Event) (Message, error) {
+	envelope, err := NewEnvelope(ctx, event)
+	if err != nil {
+		return Message{}, err
+	}
+
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Key:   event.Key(),
+		Value: value,
+		Headers: map[string]string{
+			"event_type": envelope.Type,
+			"event_id":   envelope.EventID,
+		},
+	}, nil
+}
+
+type Message struct {
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// Publish writes already encoded messages in one call. On partial failure the
+// returned error is a kafka.WriteErrors with one entry per message.
+func (p *KafkaProducer) Publish(ctx context.Context, messages ...Message) error {
+	msgs := make([]kafka.Message, 0, len(messages))
+
+	for _, m := range messages {
+		msg := kafka.Message{Key: []byte(m.Key), Value: m.Value}
+
+		for k, v := range m.Headers {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+
+		msgs = append(msgs, msg)
 	}
 
-	return p.writer.WriteMessages(ctx, msg)
+	return p.writer.WriteMessages(ctx, msgs...)
 }
 
 func (p *KafkaProducer) Close() error {
 	return p.writer.Close()
 }
+
+// MessageErrors splits the error of a Publish call of n messages into one
+// error per message; a nil entry means that message was written.
+func MessageErrors(err error, n int) []error {
+	errs := make([]error, n)
+
+	if err == nil {
+		return errs
+	}
+
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == n {
+		copy(errs, writeErrs)
+		return errs
+	}
+
+	for i := range errs {
+		errs[i] = err
+	}
+
+	return errs
+}
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Handler processes one event. Returning an error retries it; wrap the error
+// with Permanent to send the message to the dead-letter topic right away.
+type Handler func(ctx context.Context, envelope Envelope) error
+
+// Registry dispatches events to their handler by envelope type.
+type Registry struct {
+	handlers map[string]Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// Register sets the handler of an event type, replacing any earlier one.
+func (r *Registry) Register(eventType string, handler Handler) {
+	r.handlers[eventType] = handler
+}
+
+func (r *Registry) lookup(eventType string) (Handler, bool) {
+	handler, ok := r.handlers[eventType]
+
+	return handler, ok
+}
+
+// On registers a typed handler: the payload is decoded into T, whose
+// EventType selects the events it receives. A payload that does not decode
+// is a permanent failure.
+func On[T Event](r *Registry, fn func(ctx context.Context, envelope Envelope, event T) error) {
+	var zero T
+
+	r.Register(zero.EventType(), func(ctx context.Context, envelope Envelope) error {
+		var event T
+
+		if err := json.Unmarshal(envelope.Data, &event); err != nil {
+			return Permanent(fmt.Errorf("decode %s: %w", envelope.Type, err))
+		}
+
+		return fn(ctx, envelope, event)
+	})
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying cannot fix.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent *permanentError
+
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,9 @@
+package kafka
+
+type UserDeletedEvent struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+func (e UserDeletedEvent) EventType() string { return TypeUserDeleted }
+func (e UserDeletedEvent) Key() string       { return e.UserID }
@@ -0,0 +1,9 @@
+package kafka
+
+type UserEmailVerifiedEvent struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+func (e UserEmailVerifiedEvent) EventType() string { return TypeUserEmailVerified }
+func (e UserEmailVerifiedEvent) Key() string       { return e.UserID }
@@ -0,0 +1,11 @@
+package kafka
+
+// UserLockedEvent is keyed by login, like UserLoginFailedEvent.
+type UserLockedEvent struct {
+	Login       string `json:"login"`
+	IP          string `json:"ip"`
+	LockedUntil string `json:"locked_until"`
+}
+
+func (e UserLockedEvent) EventType() string { return TypeUserLocked }
+func (e UserLockedEvent) Key() string       { return e.Login }
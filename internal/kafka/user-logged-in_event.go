@@ -0,0 +1,13 @@
+package kafka
+
+type UserLoggedInEvent struct {
+	UserID    string `json:"user_id"`
+	Login     string `json:"login"`
+	SessionID string `json:"session_id"`
+	Method    string `json:"method"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+func (e UserLoggedInEvent) EventType() string { return TypeUserLoggedIn }
+func (e UserLoggedInEvent) Key() string       { return e.UserID }
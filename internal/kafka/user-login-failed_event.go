@@ -0,0 +1,11 @@
+package kafka
+
+// UserLoginFailedEvent is keyed by login: the attempt may name a user that
+// does not exist, so there is no user ID to key by.
+type UserLoginFailedEvent struct {
+	Login string `json:"login"`
+	IP    string `json:"ip"`
+}
+
+func (e UserLoginFailedEvent) EventType() string { return TypeUserLoginFailed }
+func (e UserLoginFailedEvent) Key() string       { return e.Login }
@@ -0,0 +1,15 @@
+package kafka
+
+const (
+	PasswordChanged = "change"
+	PasswordReset   = "reset"
+	PasswordUpdated = "update"
+)
+
+type UserPasswordChangedEvent struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func (e UserPasswordChangedEvent) EventType() string { return TypeUserPasswordChanged }
+func (e UserPasswordChangedEvent) Key() string       { return e.UserID }
@@ -3,5 +3,8 @@ package kafka
 type UserRegisteredEvent struct {
 	UserID string `json:"user_id"`
 	Login  string `json:"login"`
-	Time   string `json:"time"`
+	Email  string `json:"email,omitempty"`
 }
+
+func (e UserRegisteredEvent) EventType() string { return TypeUserRegistered }
+func (e UserRegisteredEvent) Key() string       { return e.UserID }
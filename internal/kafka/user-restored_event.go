@@ -0,0 +1,9 @@
+package kafka
+
+type UserRestoredEvent struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+func (e UserRestoredEvent) EventType() string { return TypeUserRestored }
+func (e UserRestoredEvent) Key() string       { return e.UserID }
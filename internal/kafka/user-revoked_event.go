@@ -0,0 +1,9 @@
+package kafka
+
+type UserRevokedEvent struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+func (e UserRevokedEvent) EventType() string { return TypeUserRevoked }
+func (e UserRevokedEvent) Key() string       { return e.UserID }
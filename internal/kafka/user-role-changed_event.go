@@ -0,0 +1,15 @@
+package kafka
+
+const (
+	RoleAssigned = "assigned"
+	RoleRemoved  = "removed"
+)
+
+type UserRoleChangedEvent struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	Action string `json:"action"`
+}
+
+func (e UserRoleChangedEvent) EventType() string { return TypeUserRoleChanged }
+func (e UserRoleChangedEvent) Key() string       { return e.UserID }
@@ -0,0 +1,10 @@
+package kafka
+
+type UserUpdatedEvent struct {
+	UserID        string   `json:"user_id"`
+	Login         string   `json:"login"`
+	ChangedFields []string `json:"changed_fields"`
+}
+
+func (e UserUpdatedEvent) EventType() string { return TypeUserUpdated }
+func (e UserUpdatedEvent) Key() string       { return e.UserID }
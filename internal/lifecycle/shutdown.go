@@ -0,0 +1,31 @@
+package lifecycle
+
+import (
+	"context"
+	"userapi/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+type Hook struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Closer adapts an io.Closer-style function, which cannot be interrupted.
+func Closer(name string, close func() error) Hook {
+	return Hook{Name: name, Fn: func(context.Context) error { return close() }}
+}
+
+// Shutdown runs the hooks in order, each one only after the previous has
+// returned. A failing hook is logged and does not stop the ones after it.
+func Shutdown(ctx context.Context, hooks ...Hook) {
+	for _, hook := range hooks {
+		if err := hook.Fn(ctx); err != nil {
+			logger.Log.Error("shutdown step failed", zap.String("step", hook.Name), zap.Error(err))
+			continue
+		}
+
+		logger.Log.Info("shutdown step done", zap.String("step", hook.Name))
+	}
+}
@@ -0,0 +1,60 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Tasks tracks fire-and-forget goroutines so shutdown can wait for them
+// instead of dropping their work.
+type Tasks struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+func NewTasks() *Tasks {
+	return &Tasks{}
+}
+
+// Go runs fn in the background. Once Wait has been called fn runs inline,
+// so work started late is neither lost nor untracked.
+func (t *Tasks) Go(fn func()) {
+	t.mu.Lock()
+
+	if t.closed {
+		t.mu.Unlock()
+		fn()
+
+		return
+	}
+
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every task has finished or ctx is done.
+func (t *Tasks) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
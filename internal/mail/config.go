@@ -0,0 +1,17 @@
+package mail
+
+import "userapi/internal/config"
+
+// NewFromConfig returns the mailer selected by MAIL_DRIVER.
+func NewFromConfig() (Mailer, error) {
+	if config.GetMailDriver() != "smtp" {
+		return NewLogMailer(config.GetMailLogFile()), nil
+	}
+
+	cfg, err := config.GetSMTPConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+}
@@ -1,99 +1,120 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
+	"userapi/internal/auth"
 	"userapi/internal/handler"
+	"userapi/internal/kafka"
 	"userapi/internal/service"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWTMiddleware(secret []byte, redis *service.RedisService) gin.HandlerFunc {
+func JWTMiddleware(validator *service.TokenValidator, redis *service.RedisService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		authHeader := c.GetHeader("Authorization")
 
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			handler.JSONErrorMsg(c, http.StatusUnauthorized, "missing or malformed token")
+			abort(c, http.StatusUnauthorized, "missing or malformed token")
 
 			return
 		}
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			return secret, nil
-		})
+		claims, err := validator.Validate(tokenStr)
 
-		if err != nil || !token.Valid {
-			handler.JSONErrorMsg(c, http.StatusUnauthorized, "invalid or expired token")
+		if err != nil {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, err.Error()))
+			abort(c, http.StatusUnauthorized, err.Error())
 
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
+		isBlacklisted, err := redis.IsBlacklisted(c.Request.Context(), claims.ID)
 
-		if !ok {
-			handler.JSONErrorMsg(c, http.StatusUnauthorized, "invalid token claims")
+		if err != nil {
+			abort(c, http.StatusInternalServerError, "internal redis error")
 
 			return
 		}
 
-		if jti, ok := claims["jti"].(string); ok {
-			isBlacklisted, err := redis.IsBlacklisted(c.Request.Context(), jti)
+		if isBlacklisted {
+			abort(c, http.StatusUnauthorized, "token revoked")
 
-			if err != nil {
-				handler.JSONErrorMsg(c, http.StatusInternalServerError, "internal redis error")
+			return
+		}
 
-				return
-			}
+		revoked, err := redis.IsUserRevoked(c.Request.Context(), claims.Subject)
 
-			if isBlacklisted {
-				handler.JSONErrorMsg(c, http.StatusUnauthorized, "token revoked")
+		if err != nil {
+			abort(c, http.StatusInternalServerError, "internal redis error")
 
-				return
-			}
+			return
+		}
 
-			c.Set("jti", jti)
-		} else {
-			handler.JSONErrorMsg(c, http.StatusUnauthorized, "token missing jti")
+		if revoked {
+			abort(c, http.StatusForbidden, "account revoked")
 
 			return
 		}
 
-		if userID, ok := claims["user_id"].(string); ok {
-			c.Set("user_id", userID)
+		validAfter, err := redis.GetTokensValidAfter(c.Request.Context(), claims.Subject)
+
+		if err != nil {
+			abort(c, http.StatusInternalServerError, "internal redis error")
+
+			return
 		}
 
-		if login, ok := claims["login"].(string); ok {
-			c.Set("login", login)
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validAfter) {
+			abort(c, http.StatusUnauthorized, "token revoked")
+
+			return
 		}
 
-		if role, ok := claims["role"].(bool); ok {
-			c.Set("role", role)
+		active, err := redis.SessionExists(c.Request.Context(), claims.Subject, claims.SessionID)
+
+		if err != nil {
+			abort(c, http.StatusInternalServerError, "internal redis error")
+
+			return
+		}
+
+		if !active {
+			abort(c, http.StatusUnauthorized, "session revoked")
+
+			return
 		}
 
+		auth.SetClaims(c, claims)
+		c.Request = c.Request.WithContext(kafka.WithActor(c.Request.Context(), claims.Login))
+
 		c.Next()
 	}
 }
 
-func RequireAdmin() gin.HandlerFunc {
+// RequirePermission must run after JWTMiddleware; permissions come from the token scopes.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		raw, exists := c.Get("role")
-		if !exists {
-			handler.JSONErrorMsg(c, http.StatusForbidden, "admin only")
-			return
-		}
+		claims, ok := auth.ClaimsFrom(c)
 
-		isAdmin, ok := raw.(bool)
-		if !ok || !isAdmin {
-			handler.JSONErrorMsg(c, http.StatusForbidden, "admin only")
+		if !ok || !claims.HasScope(permission) {
+			abort(c, http.StatusForbidden, "missing permission "+permission)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// abort writes the error response and stops the handler chain;
+// writing the response alone would still let the protected handler run.
+func abort(c *gin.Context, code int, msg string) {
+	handler.JSONErrorMsg(c, code, msg)
+	c.Abort()
+}
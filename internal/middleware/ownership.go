@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"userapi/internal/auth"
+	customErrors "userapi/internal/errors"
+	"userapi/internal/logger"
+	"userapi/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TargetResolver returns the ID of the user a request acts on.
+type TargetResolver func(c *gin.Context) (uuid.UUID, error)
+
+func UserByLoginParam(users *service.UserService, param string) TargetResolver {
+	return func(c *gin.Context) (uuid.UUID, error) {
+		user, err := users.GetByLogin(c.Param(param), false)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		return user.ID, nil
+	}
+}
+
+// RequireOwnerOrAdmin lets the request through only when the token subject is the
+// resolved target user, or the caller is an admin. Must run after JWTMiddleware.
+// Handlers read the resolved target with auth.TargetUserID.
+func RequireOwnerOrAdmin(resolve TargetResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isAdmin := auth.HasRole(c, auth.RoleAdmin)
+
+		target, err := resolve(c)
+
+		if err != nil {
+			var notFound *customErrors.NotFoundError
+
+			switch {
+			case errors.As(err, &notFound) && isAdmin:
+				abort(c, http.StatusNotFound, notFound.Error())
+			case errors.As(err, &notFound):
+				// Non-admins get the same answer for missing and foreign accounts.
+				abort(c, http.StatusForbidden, "not the resource owner")
+			default:
+				logger.WarnError(c, "ownership: resolve target failed", err)
+				abort(c, http.StatusInternalServerError, "internal error")
+			}
+
+			return
+		}
+
+		if !isAdmin && auth.UserID(c) != target.String() {
+			abort(c, http.StatusForbidden, "not the resource owner")
+			return
+		}
+
+		auth.SetTargetUserID(c, target)
+
+		c.Next()
+	}
+}
+
+// ResolveTarget only resolves the target user, for routes already guarded by a permission.
+func ResolveTarget(resolve TargetResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, err := resolve(c)
+
+		if err != nil {
+			var notFound *customErrors.NotFoundError
+
+			if errors.As(err, &notFound) {
+				abort(c, http.StatusNotFound, notFound.Error())
+			} else {
+				logger.WarnError(c, "resolve target failed", err)
+				abort(c, http.StatusInternalServerError, "internal error")
+			}
+
+			return
+		}
+
+		auth.SetTargetUserID(c, target)
+
+		c.Next()
+	}
+}
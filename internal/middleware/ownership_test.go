@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"userapi/internal/auth"
+	customErrors "userapi/internal/errors"
+	"userapi/internal/handler"
+	"userapi/internal/logger"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+	"userapi/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// memoryUsers implements the part of UserRepository a profile update uses.
+type memoryUsers struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[uuid.UUID]model.User
+}
+
+func (r *memoryUsers) GetById(id uuid.UUID, _ ...repository.QueryOption) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, &customErrors.NotFoundError{Entity: "User", Field: "id", Value: id.String()}
+	}
+
+	return &user, nil
+}
+
+func (r *memoryUsers) GetByLogin(login string, _ ...repository.QueryOption) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Login == login {
+			return &user, nil
+		}
+	}
+
+	return nil, &customErrors.NotFoundError{Entity: "User", Field: "login", Value: login}
+}
+
+func (r *memoryUsers) ModifyWithTransaction(id uuid.UUID, fn func(tx *gorm.DB, user *model.User) error) error {
+	user, err := r.GetById(id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(nil, user); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.users[id] = *user
+	r.mu.Unlock()
+
+	return nil
+}
+
+type discardOutbox struct {
+	repository.OutboxRepository
+}
+
+func (discardOutbox) AddTx(*gorm.DB, *model.OutboxEvent) error {
+	return nil
+}
+
+type profileFixture struct {
+	users  *memoryUsers
+	router *gin.Engine
+	bob    model.User
+	alice  model.User
+	carol  model.User
+}
+
+func newProfileFixture() *profileFixture {
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+
+	f := &profileFixture{
+		bob:   model.User{ID: uuid.New(), Login: "bob", Name: "Bob", Gender: 1},
+		alice: model.User{ID: uuid.New(), Login: "alice", Name: "Alice", Gender: 2},
+		carol: model.User{ID: uuid.New(), Login: "carol", Name: "Carol", Gender: 2, Admin: true},
+	}
+
+	f.users = &memoryUsers{users: map[uuid.UUID]model.User{
+		f.bob.ID:   f.bob,
+		f.alice.ID: f.alice,
+		f.carol.ID: f.carol,
+	}}
+
+	// Cache invalidation failures are only logged.
+	redisService := service.NewRedisClient(redis.NewClient(&redis.Options{
+		Dialer: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("redis is not available in tests")
+		},
+		MaxRetries: -1,
+	}))
+
+	users := service.NewUserService(f.users, nil, nil, discardOutbox{}, nil, nil, service.PasswordPolicy{}, nil, redisService, nil, nil)
+	h := handler.NewUserHandler(users, service.NewValidator(f.users), nil, nil, nil)
+
+	f.router = gin.New()
+	f.router.PUT(
+		"/users/:login",
+		signedInAs,
+		RequireOwnerOrAdmin(UserByLoginParam(users, "login")),
+		h.UpdateProfile,
+	)
+
+	return f
+}
+
+// signedInAs stands in for JWTMiddleware; the caller comes from test headers.
+func signedInAs(c *gin.Context) {
+	claims := &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: c.GetHeader("X-Test-User-ID")},
+		Login:            c.GetHeader("X-Test-Login"),
+		Roles:            []string{auth.RoleUser},
+	}
+
+	if c.GetHeader("X-Test-Admin") == "true" {
+		claims.Roles = append(claims.Roles, auth.RoleAdmin)
+	}
+
+	auth.SetClaims(c, claims)
+}
+
+func (f *profileFixture) put(caller model.User, login, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/users/"+login, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User-ID", caller.ID.String())
+	req.Header.Set("X-Test-Login", caller.Login)
+
+	if caller.Admin {
+		req.Header.Set("X-Test-Admin", "true")
+	}
+
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestUpdateProfileOwner(t *testing.T) {
+	f := newProfileFixture()
+
+	w := f.put(f.bob, "bob", `{"login":"bob","name":"Bobby","gender":1}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if got := f.users.users[f.bob.ID]; got.Name != "Bobby" || got.ModifiedBy != "bob" {
+		t.Errorf("got name=%q modified_by=%q", got.Name, got.ModifiedBy)
+	}
+}
+
+func TestUpdateProfileLoginTakenByAnotherUser(t *testing.T) {
+	f := newProfileFixture()
+
+	w := f.put(f.bob, "bob", `{"login":"alice","name":"Bob","gender":1}`)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+
+	if got := f.users.users[f.bob.ID].Login; got != "bob" {
+		t.Errorf("login changed to %q", got)
+	}
+}
+
+func TestUpdateProfileForeignUser(t *testing.T) {
+	f := newProfileFixture()
+
+	w := f.put(f.alice, "bob", `{"login":"bob","name":"Hacked","gender":1}`)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+
+	if got := f.users.users[f.bob.ID].Name; got != "Bob" {
+		t.Errorf("name changed to %q", got)
+	}
+}
+
+func TestUpdateProfileAdminBypass(t *testing.T) {
+	f := newProfileFixture()
+
+	w := f.put(f.carol, "bob", `{"login":"bob","name":"Robert","gender":1}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if got := f.users.users[f.bob.ID]; got.Name != "Robert" || got.Admin {
+		t.Errorf("got name=%q admin=%v", got.Name, got.Admin)
+	}
+}
+
+func TestUpdateProfileKeepsAdminFlag(t *testing.T) {
+	f := newProfileFixture()
+
+	w := f.put(f.carol, "carol", `{"login":"carol","name":"Caroline","gender":2}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if got := f.users.users[f.carol.ID]; got.Name != "Caroline" || !got.Admin {
+		t.Errorf("got name=%q admin=%v", got.Name, got.Admin)
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"userapi/internal/auth"
+	"userapi/internal/logger"
+	"userapi/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// KeyFunc chooses whose budget a request is charged to.
+type KeyFunc func(c *gin.Context) string
+
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser needs JWTMiddleware to run first; anonymous requests fall back to the IP.
+func KeyByUser(c *gin.Context) string {
+	if id := auth.UserID(c); id != "" {
+		return "user:" + id
+	}
+
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey hashes the X-API-Key header so raw keys never reach Redis.
+func KeyByAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "apikey:" + hex.EncodeToString(sum[:8])
+	}
+
+	return KeyByIP(c)
+}
+
+func KeyFuncByName(name string) (KeyFunc, error) {
+	switch name {
+	case "ip":
+		return KeyByIP, nil
+	case "user":
+		return KeyByUser, nil
+	case "api_key":
+		return KeyByAPIKey, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", name)
+	}
+}
+
+// RateLimit enforces policy and reports it with the IETF RateLimit-* headers.
+// If the limiter itself fails the request is let through.
+func RateLimit(limiter ratelimit.Limiter, policy ratelimit.Policy, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(c.Request.Context(), policy.Name+":"+key(c), policy.Limit, policy.Window)
+
+		if err != nil {
+			logger.Log.Warn("rate limiter failed", zap.Error(err), zap.String("policy", policy.Name))
+			c.Next()
+			return
+		}
+
+		reset := ceilSeconds(result.Reset)
+
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(max(result.Remaining, 0)))
+		c.Header("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(reset))
+			abort(c, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
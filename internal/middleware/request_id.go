@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"userapi/internal/kafka"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// RequestID propagates the caller's X-Request-ID, or generates one, and
+// stores it as the trace ID of the events the request causes.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(kafka.WithTraceID(c.Request.Context(), id))
+
+		c.Next()
+	}
+}
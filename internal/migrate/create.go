@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Create adds an empty up/down pair to the source directory dir, numbered
+// after the newest existing migration. The binary has to be rebuilt to embed it.
+func Create(dir, name string) ([]string, error) {
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("migration name %q must be snake_case", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest int64
+
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		latest = max(latest, version)
+	}
+
+	var created []string
+
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", latest+1, name, direction))
+		header := fmt.Sprintf("-- %s: %s\n", name, direction)
+
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return created, err
+		}
+
+		_, err = f.WriteString(header)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return created, err
+		}
+
+		created = append(created, file)
+	}
+
+	return created, nil
+}
@@ -0,0 +1,399 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// lockName is the MySQL advisory lock held while migrating, so that replicas
+// starting together never run the same migration twice.
+const lockName = "userapi.schema_migrations"
+
+const lockTimeoutSeconds = 60
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	AppliedOn *time.Time
+	Dirty     bool
+}
+
+// ErrSchemaBehind is returned by Check when migrations are pending.
+type ErrSchemaBehind struct {
+	Current int64
+	Latest  int64
+}
+
+func (e *ErrSchemaBehind) Error() string {
+	return fmt.Sprintf("database schema is at version %d, latest is %d; run `migrate up`", e.Current, e.Latest)
+}
+
+// ErrDirty means a migration failed halfway. MySQL cannot roll back DDL, so
+// the schema has to be repaired by hand before the row is removed.
+type ErrDirty struct {
+	Version int64
+}
+
+func (e *ErrDirty) Error() string {
+	return fmt.Sprintf("migration %d failed halfway, fix the schema and delete its schema_migrations row", e.Version)
+}
+
+// Load reads the embedded migrations, ordered by version.
+func Load() ([]Migration, error) {
+	return load(embedded, "migrations")
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := cleanVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			if err := apply(ctx, conn, migration.Version, migration.Name, migration.Up, true); err != nil {
+				return err
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := cleanVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			if err := apply(ctx, conn, migration.Version, migration.Name, migration.Down, false); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+
+			if row, ok := done[migration.Version]; ok {
+				appliedOn := row.appliedOn
+				status.AppliedOn = &appliedOn
+				status.Dirty = row.dirty
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Check fails unless every known migration has been applied cleanly.
+// The API calls it at startup instead of migrating on its own.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		current, latest int64
+		pending         bool
+	)
+
+	for _, status := range statuses {
+		latest = status.Version
+
+		switch {
+		case status.Dirty:
+			return &ErrDirty{Version: status.Version}
+		case status.AppliedOn == nil:
+			pending = true
+		default:
+			current = status.Version
+		}
+	}
+
+	if pending {
+		return &ErrSchemaBehind{Current: current, Latest: latest}
+	}
+
+	return nil
+}
+
+type appliedRow struct {
+	appliedOn time.Time
+	dirty     bool
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedRow, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_on, dirty FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int64]appliedRow)
+
+	for rows.Next() {
+		var (
+			version int64
+			row     appliedRow
+		)
+
+		if err := rows.Scan(&version, &row.appliedOn, &row.dirty); err != nil {
+			return nil, err
+		}
+
+		done[version] = row
+	}
+
+	return done, rows.Err()
+}
+
+// cleanVersions refuses to go on while a failed migration is unresolved.
+func cleanVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedRow, error) {
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for version, row := range done {
+		if row.dirty {
+			return nil, &ErrDirty{Version: version}
+		}
+	}
+
+	return done, nil
+}
+
+// apply runs one migration file. The bookkeeping row is written as dirty
+// first and cleaned up afterwards, so a crash in between is detectable.
+func apply(ctx context.Context, conn *sql.Conn, version int64, name, script string, up bool) error {
+	if up {
+		if _, err := conn.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, applied_on, dirty) VALUES (?, ?, ?, true)",
+			version, name, time.Now().UTC(),
+		); err != nil {
+			return err
+		}
+	} else if _, err := conn.ExecContext(ctx, "UPDATE schema_migrations SET dirty = true WHERE version = ?", version); err != nil {
+		return err
+	}
+
+	for _, statement := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", version, name, err)
+		}
+	}
+
+	if up {
+		_, err := conn.ExecContext(ctx, "UPDATE schema_migrations SET dirty = false WHERE version = ?", version)
+		return err
+	}
+
+	_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", version)
+
+	return err
+}
+
+// splitStatements cuts a script at semicolons that end a line; the driver
+// runs one statement per call unless multiStatements is enabled.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// locked runs fn on a single connection holding the advisory lock; GET_LOCK
+// is bound to the session, so everything must use that same connection.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	return m.withConn(ctx, func(conn *sql.Conn) error {
+		var got sql.NullInt64
+
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeoutSeconds).Scan(&got); err != nil {
+			return err
+		}
+
+		if !got.Valid || got.Int64 != 1 {
+			return fmt.Errorf("could not acquire migration lock within %ds", lockTimeoutSeconds)
+		}
+
+		defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", lockName)
+
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint       NOT NULL,
+		name       varchar(255) NOT NULL,
+		applied_on datetime(3)  NOT NULL,
+		dirty      boolean      NOT NULL,
+		PRIMARY KEY (version)
+	) ENGINE = InnoDB`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Fatalf("migration %d_%s is out of sequence", migration.Version, migration.Name)
+		}
+
+		for _, statement := range splitStatements(migration.Up) {
+			if strings.HasSuffix(statement, ";") || strings.Contains(statement, ";\n") {
+				t.Errorf("migration %d_%s: statement %q was not split", migration.Version, migration.Name, statement)
+			}
+		}
+	}
+}
+
+// legacyUsers is the users table AutoMigrate created from the original model,
+// before email and TOTP support.
+const legacyUsers = `CREATE TABLE users (
+    id          char(36)     NOT NULL,
+    login       varchar(191) NOT NULL,
+    password    longtext     NOT NULL,
+    name        longtext     NOT NULL,
+    gender      bigint       NOT NULL,
+    birthday    datetime(3)  NULL,
+    admin       boolean      NOT NULL,
+    created_on  datetime(3)  NULL,
+    created_by  longtext     NULL,
+    modified_on datetime(3)  NULL,
+    modified_by longtext     NULL,
+    revoked_on  datetime(3)  NULL,
+    revoked_by  longtext     NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uni_users_login (login)
+)`
+
+// TestBaselineAdoptsLegacySchema needs USERAPI_TEST_MIGRATE_DSN to point to an
+// empty, disposable database. It migrates a legacy users table all the way up
+// and reverts everything afterwards.
+func TestBaselineAdoptsLegacySchema(t *testing.T) {
+	dsn := os.Getenv("USERAPI_TEST_MIGRATE_DSN")
+	if dsn == "" {
+		t.Skip("USERAPI_TEST_MIGRATE_DSN not set")
+	}
+
+	gormDB, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	db, err := gormDB.DB()
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, legacyUsers); err != nil {
+		t.Fatalf("create legacy users: %v", err)
+	}
+
+	if _, err := db.ExecContext(ctx,
+		"INSERT INTO users (id, login, password, name, gender, admin) VALUES (UUID(), 'legacy', 'x', 'Legacy', 0, false)",
+	); err != nil {
+		t.Fatalf("insert legacy user: %v", err)
+	}
+
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := m.Down(ctx, len(m.migrations)); err != nil {
+			t.Errorf("Down: %v", err)
+		}
+
+		db.ExecContext(ctx, "DROP TABLE IF EXISTS schema_migrations")
+	})
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	for _, column := range []string{"email", "email_verified_on", "totp_secret", "totp_enabled"} {
+		if !exists(t, db, "information_schema.columns", "column_name", column) {
+			t.Errorf("users.%s is missing", column)
+		}
+	}
+
+	for _, index := range []string{"idx_users_email", "idx_users_search"} {
+		if !exists(t, db, "information_schema.statistics", "index_name", index) {
+			t.Errorf("index %s is missing", index)
+		}
+	}
+
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
+
+func exists(t *testing.T, db *sql.DB, table, column, name string) bool {
+	t.Helper()
+
+	var n int
+
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM "+table+" WHERE table_schema = DATABASE() AND table_name = 'users' AND "+column+" = ?",
+		name,
+	).Scan(&n)
+	if err != nil {
+		t.Fatalf("query %s: %v", table, err)
+	}
+
+	return n > 0
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS password_histories;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,145 @@
+-- Baseline: the schema AutoMigrate used to create. IF NOT EXISTS lets
+-- databases created by AutoMigrate adopt it; their users table may predate
+-- the email and TOTP columns, which are added below when missing.
+
+CREATE TABLE IF NOT EXISTS users (
+    id                char(36)     NOT NULL,
+    login             varchar(191) NOT NULL,
+    email             varchar(255) NULL,
+    email_verified_on datetime(3)  NULL,
+    password          longtext     NOT NULL,
+    name              varchar(255) NOT NULL,
+    gender            bigint       NOT NULL,
+    birthday          datetime(3)  NULL,
+    admin             boolean      NOT NULL,
+    totp_secret       varchar(64)  NULL,
+    totp_enabled      boolean      NOT NULL DEFAULT false,
+    created_on        datetime(3)  NULL,
+    created_by        longtext     NULL,
+    modified_on       datetime(3)  NULL,
+    modified_by       longtext     NULL,
+    revoked_on        datetime(3)  NULL,
+    revoked_by        longtext     NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uni_users_login (login),
+    UNIQUE KEY idx_users_email (email)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+
+-- MySQL has no ADD COLUMN IF NOT EXISTS, hence the prepared statements.
+
+SET @ddl := IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'email') = 0,
+    'ALTER TABLE users ADD COLUMN email varchar(255) NULL AFTER login',
+    'DO 0'
+);
+
+PREPARE stmt FROM @ddl;
+
+EXECUTE stmt;
+
+DEALLOCATE PREPARE stmt;
+
+SET @ddl := IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'email_verified_on') = 0,
+    'ALTER TABLE users ADD COLUMN email_verified_on datetime(3) NULL AFTER email',
+    'DO 0'
+);
+
+PREPARE stmt FROM @ddl;
+
+EXECUTE stmt;
+
+DEALLOCATE PREPARE stmt;
+
+SET @ddl := IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'totp_secret') = 0,
+    'ALTER TABLE users ADD COLUMN totp_secret varchar(64) NULL AFTER admin',
+    'DO 0'
+);
+
+PREPARE stmt FROM @ddl;
+
+EXECUTE stmt;
+
+DEALLOCATE PREPARE stmt;
+
+SET @ddl := IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'totp_enabled') = 0,
+    'ALTER TABLE users ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false AFTER totp_secret',
+    'DO 0'
+);
+
+PREPARE stmt FROM @ddl;
+
+EXECUTE stmt;
+
+DEALLOCATE PREPARE stmt;
+
+SET @ddl := IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'users' AND index_name = 'idx_users_email') = 0,
+    'CREATE UNIQUE INDEX idx_users_email ON users (email)',
+    'DO 0'
+);
+
+PREPARE stmt FROM @ddl;
+
+EXECUTE stmt;
+
+DEALLOCATE PREPARE stmt;
+
+CREATE TABLE IF NOT EXISTS roles (
+    id          bigint unsigned NOT NULL AUTO_INCREMENT,
+    name        varchar(64)     NOT NULL,
+    description longtext        NULL,
+    created_on  datetime(3)     NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_roles_name (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id          bigint unsigned NOT NULL AUTO_INCREMENT,
+    name        varchar(64)     NOT NULL,
+    description longtext        NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_permissions_name (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id       bigint unsigned NOT NULL,
+    permission_id bigint unsigned NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT fk_role_permissions_role FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_role_permissions_permission FOREIGN KEY (permission_id) REFERENCES permissions (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id char(36)        NOT NULL,
+    role_id bigint unsigned NOT NULL,
+    PRIMARY KEY (user_id, role_id),
+    CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_user_roles_role FOREIGN KEY (role_id) REFERENCES roles (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS password_histories (
+    id         bigint unsigned NOT NULL AUTO_INCREMENT,
+    user_id    char(36)        NOT NULL,
+    hash       longtext        NOT NULL,
+    created_on datetime(3)     NULL,
+    PRIMARY KEY (id),
+    KEY idx_password_histories_user_id (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         bigint unsigned NOT NULL AUTO_INCREMENT,
+    user_id    char(36)        NOT NULL,
+    hash       varchar(64)     NOT NULL,
+    used_on    datetime(3)     NULL,
+    created_on datetime(3)     NULL,
+    PRIMARY KEY (id),
+    KEY idx_recovery_codes_user_id (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
@@ -0,0 +1,15 @@
+-- Full-text index behind the admin user search. AutoMigrate may already have
+-- created it from the old model tags, and MySQL has no CREATE INDEX IF NOT EXISTS.
+
+SET @ddl := IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'users' AND index_name = 'idx_users_search') = 0,
+    'CREATE FULLTEXT INDEX idx_users_search ON users (login, name, email)',
+    'DO 0'
+);
+
+PREPARE stmt FROM @ddl;
+
+EXECUTE stmt;
+
+DEALLOCATE PREPARE stmt;
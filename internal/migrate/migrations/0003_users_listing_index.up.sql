@@ -0,0 +1,2 @@
+-- Keyset pagination of the admin listing orders by created_on, id.
+CREATE INDEX idx_users_created_on ON users (created_on, id);
@@ -0,0 +1,19 @@
+-- Transactional outbox: events are written in the same transaction as the
+-- change they describe and published to Kafka by the relay afterwards.
+
+CREATE TABLE outbox_events (
+    id              bigint unsigned NOT NULL AUTO_INCREMENT,
+    aggregate_type  varchar(64)     NOT NULL,
+    aggregate_id    char(36)        NOT NULL,
+    event_type      varchar(128)    NOT NULL,
+    payload         json            NOT NULL,
+    created_on      datetime(3)     NOT NULL,
+    attempts        int             NOT NULL DEFAULT 0,
+    next_attempt_on datetime(3)     NOT NULL,
+    last_error      text            NULL,
+    published_on    datetime(3)     NULL,
+    failed_on       datetime(3)     NULL,
+    PRIMARY KEY (id),
+    KEY idx_outbox_events_pending (published_on, failed_on, next_attempt_on),
+    KEY idx_outbox_events_aggregate (aggregate_id, id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
@@ -0,0 +1,17 @@
+-- Audit trail written by the event consumer. event_id is unique because
+-- delivery is at least once and the same event may arrive twice.
+
+CREATE TABLE audit_records (
+    id          bigint unsigned NOT NULL AUTO_INCREMENT,
+    event_id    char(36)        NOT NULL,
+    event_type  varchar(128)    NOT NULL,
+    user_id     char(36)        NULL,
+    actor       varchar(255)    NOT NULL,
+    trace_id    varchar(128)    NULL,
+    occurred_on datetime(3)     NOT NULL,
+    payload     json            NOT NULL,
+    created_on  datetime(3)     NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uni_audit_records_event_id (event_id),
+    KEY idx_audit_records_user (user_id, occurred_on)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
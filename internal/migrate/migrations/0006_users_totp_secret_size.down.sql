@@ -0,0 +1,2 @@
+-- Fails while encrypted secrets longer than 64 characters are stored.
+ALTER TABLE users MODIFY totp_secret varchar(64) NULL;
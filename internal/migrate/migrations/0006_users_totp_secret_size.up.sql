@@ -0,0 +1,2 @@
+-- TOTP secrets are stored encrypted, which no longer fits in 64 characters.
+ALTER TABLE users MODIFY totp_secret varchar(255) NULL;
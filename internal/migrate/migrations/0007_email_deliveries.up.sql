@@ -0,0 +1,10 @@
+-- Mails sent by the event consumer. A row is written only after the mail
+-- went out, so a failed send is retried and a redelivered event is not
+-- mailed twice.
+
+CREATE TABLE email_deliveries (
+    event_id char(36)    NOT NULL,
+    kind     varchar(32) NOT NULL,
+    sent_on  datetime(3) NOT NULL,
+    PRIMARY KEY (event_id, kind)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci;
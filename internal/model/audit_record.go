@@ -0,0 +1,18 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditRecord struct {
+	ID         uint64          `gorm:"primaryKey"`
+	EventID    string          `gorm:"size:36;not null;uniqueIndex"`
+	EventType  string          `gorm:"size:128;not null"`
+	UserID     *string         `gorm:"size:36"`
+	Actor      string          `gorm:"size:255;not null"`
+	TraceID    *string         `gorm:"size:128"`
+	OccurredOn time.Time       `gorm:"not null"`
+	Payload    json.RawMessage `gorm:"type:json;not null"`
+	CreatedOn  time.Time       `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+type EmailDelivery struct {
+	EventID string    `gorm:"primaryKey;size:36"`
+	Kind    string    `gorm:"primaryKey;size:32"`
+	SentOn  time.Time `gorm:"autoCreateTime"`
+}
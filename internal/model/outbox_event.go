@@ -0,0 +1,20 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OutboxEvent struct {
+	ID            uint64          `gorm:"primaryKey"`
+	AggregateType string          `gorm:"size:64;not null"`
+	AggregateID   string          `gorm:"size:36;not null"`
+	EventType     string          `gorm:"size:128;not null"`
+	Payload       json.RawMessage `gorm:"type:json;not null"`
+	CreatedOn     time.Time       `gorm:"not null"`
+	Attempts      int             `gorm:"not null;default:0"`
+	NextAttemptOn time.Time       `gorm:"not null"`
+	LastError     *string
+	PublishedOn   *time.Time
+	FailedOn      *time.Time
+}
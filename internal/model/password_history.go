@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uuid.UUID `gorm:"type:char(36);index;not null"`
+	Hash      string    `gorm:"not null"`
+	CreatedOn time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RecoveryCode struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uuid.UUID `gorm:"type:char(36);index;not null"`
+	Hash      string    `gorm:"size:64;not null"`
+	UsedOn    *time.Time
+	CreatedOn time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type Role struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;size:64;not null"`
+	Description string
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+	CreatedOn   time.Time    `gorm:"autoCreateTime"`
+}
+
+type Permission struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;size:64;not null"`
+	Description string
+}
@@ -7,17 +7,22 @@ import (
 )
 
 type User struct {
-	ID         uuid.UUID `gorm:"type:char(36);primaryKey"`
-	Login      string    `gorm:"unique;not null"`
-	Password   string    `gorm:"not null"`
-	Name       string    `gorm:"not null"`
-	Gender     int       `gorm:"not null"`
-	Birthday   *time.Time
-	Admin      bool      `gorm:"not null"`
-	CreatedOn  time.Time `gorm:"autoCreateTime"`
-	CreatedBy  string
-	ModifiedOn time.Time `gorm:"autoUpdateTime"`
-	ModifiedBy string
-	RevokedOn  *time.Time
-	RevokedBy  *string
+	ID              uuid.UUID `gorm:"type:char(36);primaryKey"`
+	Login           string    `gorm:"unique;not null"`
+	Email           *string   `gorm:"uniqueIndex;size:255"`
+	EmailVerifiedOn *time.Time
+	Password        string `gorm:"not null" json:"-"`
+	Name            string `gorm:"not null;size:255"`
+	Gender          int    `gorm:"not null"`
+	Birthday        *time.Time
+	Admin           bool      `gorm:"not null"`
+	TOTPSecret      *string   `gorm:"column:totp_secret;size:255" json:"-"`
+	TOTPEnabled     bool      `gorm:"column:totp_enabled;not null;default:false"`
+	CreatedOn       time.Time `gorm:"autoCreateTime"`
+	CreatedBy       string
+	ModifiedOn      time.Time `gorm:"autoUpdateTime"`
+	ModifiedBy      string
+	RevokedOn       *time.Time
+	RevokedBy       *string
+	Roles           []Role `gorm:"many2many:user_roles"`
 }
@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+	"time"
+	"userapi/internal/kafka"
+	"userapi/internal/logger"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	baseBackoff    = time.Second
+	maxBackoff     = 5 * time.Minute
+	publishTimeout = 30 * time.Second
+	purgeBatch     = 1000
+)
+
+type Publisher interface {
+	Publish(ctx context.Context, messages ...kafka.Message) error
+}
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	Retention    time.Duration
+}
+
+// Relay publishes outbox events to Kafka. Delivery is at least once: an event
+// is marked published only after Kafka acknowledged it, so a crash in between
+// publishes it again. Consumers have to tolerate duplicates.
+type Relay struct {
+	repo      repository.OutboxRepository
+	publisher Publisher
+	cfg       Config
+}
+
+func NewRelay(repo repository.OutboxRepository, publisher Publisher, cfg Config) *Relay {
+	return &Relay{repo: repo, publisher: publisher, cfg: cfg}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.drain(ctx)
+		r.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain keeps going while batches come back full, so a backlog does not
+// have to wait for the next tick.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.repo.Process(ctx, r.cfg.BatchSize, r.publish)
+
+		if err != nil {
+			logger.Log.Warn("outbox relay failed", zap.Error(err))
+			return
+		}
+
+		if n < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) publish(tx *gorm.DB, events []model.OutboxEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+
+	for _, event := range events {
+		messages = append(messages, kafka.Message{
+			Key:   event.AggregateID,
+			Value: event.Payload,
+			Headers: map[string]string{
+				"event_type": event.EventType,
+				"outbox_id":  strconv.FormatUint(event.ID, 10),
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(tx.Statement.Context, publishTimeout)
+	defer cancel()
+
+	errs := kafka.MessageErrors(r.publisher.Publish(ctx, messages...), len(messages))
+	now := time.Now().UTC()
+
+	var published []uint64
+
+	for i := range events {
+		if errs[i] == nil {
+			published = append(published, events[i].ID)
+			continue
+		}
+
+		r.scheduleRetry(&events[i], errs[i], now)
+
+		if err := r.repo.MarkFailedTx(tx, &events[i]); err != nil {
+			return err
+		}
+	}
+
+	return r.repo.MarkPublishedTx(tx, published, now)
+}
+
+// scheduleRetry backs off exponentially. After MaxAttempts the event is
+// parked as failed so it stops blocking later events of its aggregate.
+func (r *Relay) scheduleRetry(event *model.OutboxEvent, err error, now time.Time) {
+	msg := err.Error()
+	event.Attempts++
+	event.LastError = &msg
+
+	if event.Attempts >= r.cfg.MaxAttempts {
+		event.FailedOn = &now
+
+		logger.Log.Error("outbox event failed permanently",
+			zap.Uint64("id", event.ID),
+			zap.String("type", event.EventType),
+			zap.String("aggregate_id", event.AggregateID),
+			zap.Error(err),
+		)
+
+		return
+	}
+
+	backoff := baseBackoff << min(event.Attempts-1, 16)
+	event.NextAttemptOn = now.Add(min(backoff, maxBackoff))
+
+	logger.Log.Warn("outbox event publish failed, will retry",
+		zap.Uint64("id", event.ID),
+		zap.Int("attempts", event.Attempts),
+		zap.Error(err),
+	)
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	if r.cfg.Retention <= 0 {
+		return
+	}
+
+	if _, err := r.repo.DeletePublishedBefore(ctx, time.Now().UTC().Add(-r.cfg.Retention), purgeBatch); err != nil {
+		logger.Log.Warn("outbox purge failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// Limiter implements a sliding-window limit: at most limit requests per key
+// within any window-long interval.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// ParsePolicy reads specs like "10/1m" or "100/1h".
+func ParsePolicy(name, spec string) (Policy, error) {
+	limitPart, windowPart, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %s: expected <limit>/<window>, got %q", name, spec)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitPart))
+	if err != nil || limit <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s: invalid limit %q", name, limitPart)
+	}
+
+	window, err := time.ParseDuration(strings.TrimSpace(windowPart))
+	if err != nil || window <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s: invalid window %q", name, windowPart)
+	}
+
+	return Policy{Name: name, Limit: limit, Window: window}, nil
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Allow drops keys that have gone idle.
+const sweepInterval = time.Minute
+
+type window struct {
+	hits   []time.Time
+	length time.Duration
+}
+
+// MemoryLimiter is a process-local fallback for tests and single-instance setups.
+// Keys whose window has emptied are removed, so memory stays bounded by the
+// keys active within their window.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{windows: make(map[string]*window), now: time.Now}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit int, length time.Duration) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	w := l.windows[key]
+	if w == nil {
+		w = &window{}
+	}
+
+	w.length = length
+	w.prune(now)
+
+	allowed := len(w.hits) < limit
+	if allowed {
+		w.hits = append(w.hits, now)
+	}
+
+	if len(w.hits) == 0 {
+		delete(l.windows, key)
+	} else {
+		l.windows[key] = w
+	}
+
+	reset := length
+	if len(w.hits) > 0 {
+		reset = w.hits[0].Add(length).Sub(now)
+	}
+
+	return Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: limit - len(w.hits),
+		Reset:     reset,
+	}, nil
+}
+
+// Len reports how many keys are tracked.
+func (l *MemoryLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.windows)
+}
+
+// sweep runs at most once per sweepInterval and drops every key without a
+// hit inside its window. Callers hold l.mu.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, w := range l.windows {
+		if w.prune(now); len(w.hits) == 0 {
+			delete(l.windows, key)
+		}
+	}
+}
+
+func (w *window) prune(now time.Time) {
+	cutoff := now.Add(-w.length)
+
+	i := 0
+	for i < len(w.hits) && !w.hits[i].After(cutoff) {
+		i++
+	}
+
+	w.hits = w.hits[i:]
+}
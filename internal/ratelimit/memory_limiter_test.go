@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter() (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter()
+	limiter.now = clock.now
+
+	return limiter, clock
+}
+
+func allow(t *testing.T, l *MemoryLimiter, key string, limit int, window time.Duration) Result {
+	t.Helper()
+
+	result, err := l.Allow(context.Background(), key, limit, window)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	return result
+}
+
+func TestMemoryLimiterSlidingWindow(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	for i := 0; i < 3; i++ {
+		r := allow(t, l, "k", 3, time.Minute)
+		if !r.Allowed || r.Remaining != 2-i {
+			t.Fatalf("request %d: got allowed=%v remaining=%d", i, r.Allowed, r.Remaining)
+		}
+
+		clock.advance(10 * time.Second)
+	}
+
+	r := allow(t, l, "k", 3, time.Minute)
+	if r.Allowed {
+		t.Fatal("fourth request within the window was allowed")
+	}
+
+	if r.Reset != 30*time.Second {
+		t.Fatalf("reset = %v, want 30s until the oldest hit leaves the window", r.Reset)
+	}
+
+	// The first hit leaves the window; the other two still count.
+	clock.advance(30 * time.Second)
+
+	if r := allow(t, l, "k", 3, time.Minute); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("after the oldest hit expired: allowed=%v remaining=%d", r.Allowed, r.Remaining)
+	}
+
+	if r := allow(t, l, "k", 3, time.Minute); r.Allowed {
+		t.Fatal("request over the limit was allowed")
+	}
+}
+
+func TestMemoryLimiterKeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	allow(t, l, "a", 1, time.Minute)
+
+	if r := allow(t, l, "a", 1, time.Minute); r.Allowed {
+		t.Fatal("second request for a was allowed")
+	}
+
+	if r := allow(t, l, "b", 1, time.Minute); !r.Allowed {
+		t.Fatal("first request for b was rejected")
+	}
+}
+
+func TestMemoryLimiterSweepsIdleKeys(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	for _, key := range []string{"a", "b", "c"} {
+		allow(t, l, key, 5, 10*time.Second)
+	}
+
+	if n := l.Len(); n != 3 {
+		t.Fatalf("tracked keys = %d, want 3", n)
+	}
+
+	clock.advance(sweepInterval)
+	allow(t, l, "d", 5, 10*time.Second)
+
+	if n := l.Len(); n != 1 {
+		t.Fatalf("tracked keys after sweep = %d, want 1", n)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("login", "10/1m")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+
+	if p.Limit != 10 || p.Window != time.Minute {
+		t.Fatalf("got %+v", p)
+	}
+
+	for _, spec := range []string{"10", "0/1m", "x/1m", "10/0s", "10/soon"} {
+		if _, err := ParsePolicy("login", spec); err == nil {
+			t.Errorf("ParsePolicy(%q) succeeded", spec)
+		}
+	}
+}
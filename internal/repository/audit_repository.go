@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"userapi/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, record *model.AuditRecord) (bool, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Create ignores records whose event_id is already stored, so redelivered
+// events are audited once. It reports whether the record was inserted.
+func (r *auditRepository) Create(ctx context.Context, record *model.AuditRecord) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(record)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"userapi/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailDeliveryRepository remembers which mails an event already caused.
+type EmailDeliveryRepository interface {
+	Sent(ctx context.Context, eventID, kind string) (bool, error)
+	MarkSent(ctx context.Context, eventID, kind string) error
+}
+
+type emailDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailDeliveryRepository(db *gorm.DB) EmailDeliveryRepository {
+	return &emailDeliveryRepository{db: db}
+}
+
+func (r *emailDeliveryRepository) Sent(ctx context.Context, eventID, kind string) (bool, error) {
+	var count int64
+
+	err := r.db.WithContext(ctx).
+		Model(&model.EmailDelivery{}).
+		Where("event_id = ? AND kind = ?", eventID, kind).
+		Count(&count).Error
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *emailDeliveryRepository) MarkSent(ctx context.Context, eventID, kind string) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.EmailDelivery{EventID: eventID, Kind: kind}).Error
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"userapi/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	AddTx(tx *gorm.DB, event *model.OutboxEvent) error
+	Process(ctx context.Context, limit int, fn func(tx *gorm.DB, events []model.OutboxEvent) error) (int, error)
+	MarkPublishedTx(tx *gorm.DB, ids []uint64, at time.Time) error
+	MarkFailedTx(tx *gorm.DB, event *model.OutboxEvent) error
+	DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) AddTx(tx *gorm.DB, event *model.OutboxEvent) error {
+	return tx.Create(event).Error
+}
+
+// Process locks a batch of due events and hands them to fn inside one
+// transaction. SKIP LOCKED lets several relays share the table. Only the
+// oldest unfinished event of each aggregate is eligible, so a relay never
+// overtakes an earlier event of the same user that another relay holds or
+// that is waiting for a retry.
+func (r *outboxRepository) Process(ctx context.Context, limit int, fn func(tx *gorm.DB, events []model.OutboxEvent) error) (int, error) {
+	var claimed int
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []model.OutboxEvent
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_on IS NULL AND failed_on IS NULL AND next_attempt_on <= ?", time.Now().UTC()).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM outbox_events earlier
+				WHERE earlier.aggregate_id = outbox_events.aggregate_id
+				AND earlier.published_on IS NULL AND earlier.failed_on IS NULL
+				AND earlier.id < outbox_events.id)`).
+			Order("id").
+			Limit(limit).
+			Find(&events).Error
+
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		claimed = len(events)
+
+		return fn(tx, events)
+	})
+
+	return claimed, err
+}
+
+func (r *outboxRepository) MarkPublishedTx(tx *gorm.DB, ids []uint64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return tx.Model(&model.OutboxEvent{}).Where("id IN ?", ids).Update("published_on", at).Error
+}
+
+// MarkFailedTx stores the retry bookkeeping the relay set on event.
+func (r *outboxRepository) MarkFailedTx(tx *gorm.DB, event *model.OutboxEvent) error {
+	return tx.Model(event).Select("attempts", "next_attempt_on", "last_error", "failed_on").Updates(event).Error
+}
+
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("published_on < ?", before).
+		Limit(limit).
+		Delete(&model.OutboxEvent{})
+
+	return result.RowsAffected, result.Error
+}
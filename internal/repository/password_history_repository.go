@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"userapi/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository interface {
+	RecentTx(tx *gorm.DB, userID uuid.UUID, limit int) ([]model.PasswordHistory, error)
+	AddTx(tx *gorm.DB, userID uuid.UUID, hash string, keep int) error
+}
+
+type passwordHistoryRepository struct{}
+
+func NewPasswordHistoryRepository() PasswordHistoryRepository {
+	return &passwordHistoryRepository{}
+}
+
+func (r *passwordHistoryRepository) RecentTx(tx *gorm.DB, userID uuid.UUID, limit int) ([]model.PasswordHistory, error) {
+	var history []model.PasswordHistory
+
+	err := tx.Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&history).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// AddTx stores a previous password hash and drops everything beyond the newest keep entries.
+func (r *passwordHistoryRepository) AddTx(tx *gorm.DB, userID uuid.UUID, hash string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	if err := tx.Create(&model.PasswordHistory{UserID: userID, Hash: hash}).Error; err != nil {
+		return err
+	}
+
+	recent, err := r.RecentTx(tx, userID, keep)
+	if err != nil {
+		return err
+	}
+
+	if len(recent) < keep {
+		return nil
+	}
+
+	oldest := recent[len(recent)-1].ID
+
+	return tx.Where("user_id = ? AND id < ?", userID, oldest).Delete(&model.PasswordHistory{}).Error
+}
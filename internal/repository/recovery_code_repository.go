@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"time"
+	"userapi/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceTx(tx *gorm.DB, userID uuid.UUID, hashes []string) error
+	DeleteAllTx(tx *gorm.DB, userID uuid.UUID) error
+	Use(userID uuid.UUID, hash string) (bool, error)
+	UseTx(tx *gorm.DB, userID uuid.UUID, hash string) (bool, error)
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+func (r *recoveryCodeRepository) ReplaceTx(tx *gorm.DB, userID uuid.UUID, hashes []string) error {
+	if err := r.DeleteAllTx(tx, userID); err != nil {
+		return err
+	}
+
+	codes := make([]model.RecoveryCode, len(hashes))
+
+	for i, hash := range hashes {
+		codes[i] = model.RecoveryCode{UserID: userID, Hash: hash}
+	}
+
+	return tx.Create(&codes).Error
+}
+
+func (r *recoveryCodeRepository) DeleteAllTx(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+}
+
+// Use marks an unused code as used and reports whether one was found.
+// The conditional update makes concurrent use of the same code impossible.
+func (r *recoveryCodeRepository) Use(userID uuid.UUID, hash string) (bool, error) {
+	return r.UseTx(r.db, userID, hash)
+}
+
+func (r *recoveryCodeRepository) UseTx(tx *gorm.DB, userID uuid.UUID, hash string) (bool, error) {
+	result := tx.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND hash = ? AND used_on IS NULL", userID, hash).
+		Update("used_on", time.Now())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	customErrors "userapi/internal/errors"
+	"userapi/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository interface {
+	GetAll() ([]model.Role, error)
+	GetByName(name string) (*model.Role, error)
+	Create(role *model.Role) error
+	Delete(name string) error
+	ReplacePermissions(role *model.Role, permissions []model.Permission) error
+	GetPermissions() ([]model.Permission, error)
+	GetPermissionsByNames(names []string) ([]model.Permission, error)
+	EnsurePermission(permission *model.Permission) error
+	EnsureRole(role *model.Role) error
+	AssignToUser(userID uuid.UUID, role *model.Role, fn func(tx *gorm.DB) error) error
+	RemoveFromUser(userID uuid.UUID, role *model.Role, fn func(tx *gorm.DB) error) error
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) GetAll() ([]model.Role, error) {
+	var roles []model.Role
+
+	err := r.db.Preload("Permissions").Order("name").Find(&roles).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) GetByName(name string) (*model.Role, error) {
+	var role model.Role
+
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+
+	if err != nil {
+		return wrapNotFound[model.Role](err, "Role", "name", name)
+	}
+
+	return &role, nil
+}
+
+func (r *roleRepository) Create(role *model.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *roleRepository) Delete(name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var role model.Role
+
+		if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+			return wrapNotFoundErr("Role", "name", name, err)
+		}
+
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&role).Error
+	})
+}
+
+func (r *roleRepository) ReplacePermissions(role *model.Role, permissions []model.Permission) error {
+	return r.db.Model(role).Association("Permissions").Replace(permissions)
+}
+
+func (r *roleRepository) GetPermissions() ([]model.Permission, error) {
+	var permissions []model.Permission
+
+	err := r.db.Order("name").Find(&permissions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (r *roleRepository) GetPermissionsByNames(names []string) ([]model.Permission, error) {
+	var permissions []model.Permission
+
+	if len(names) == 0 {
+		return permissions, nil
+	}
+
+	err := r.db.Where("name IN ?", names).Find(&permissions).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (r *roleRepository) EnsurePermission(permission *model.Permission) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(permission).Error
+}
+
+// EnsureRole creates the role if it is missing and reloads it with its permissions.
+func (r *roleRepository) EnsureRole(role *model.Role) error {
+	return r.db.Where(model.Role{Name: role.Name}).
+		Attrs(model.Role{Description: role.Description}).
+		Preload("Permissions").
+		FirstOrCreate(role).Error
+}
+
+// AssignToUser and RemoveFromUser run fn in the same transaction as the
+// change. Assigning a role the user already has changes nothing, so fn is
+// not called.
+func (r *roleRepository) AssignToUser(userID uuid.UUID, role *model.Role, fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		user := model.User{ID: userID}
+
+		if err := tx.Select("id").Where("id = ?", userID).First(&user).Error; err != nil {
+			return wrapNotFoundErr("User", "id", userID.String(), err)
+		}
+
+		var assigned int64
+
+		err := tx.Table("user_roles").
+			Where("user_id = ? AND role_id = ?", userID, role.ID).
+			Count(&assigned).Error
+
+		if err != nil {
+			return err
+		}
+
+		if assigned > 0 {
+			return nil
+		}
+
+		if err := tx.Model(&user).Association("Roles").Append(role); err != nil {
+			return err
+		}
+
+		return fn(tx)
+	})
+}
+
+func (r *roleRepository) RemoveFromUser(userID uuid.UUID, role *model.Role, fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userID, role.ID)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return &customErrors.NotFoundError{Entity: "UserRole", Field: "role", Value: role.Name}
+		}
+
+		return fn(tx)
+	})
+}
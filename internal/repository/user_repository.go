@@ -2,6 +2,9 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 	customErrors "userapi/internal/errors"
 	"userapi/internal/model"
 
@@ -12,16 +15,20 @@ import (
 
 type UserRepository interface {
 	Create(user *model.User) error
-	GetById(id uuid.UUID) (*model.User, error)
-	GetAll() ([]model.User, error)
-	GetByLogin(login string) (*model.User, error)
+	GetById(id uuid.UUID, opts ...QueryOption) (*model.User, error)
+	GetAll(opts ...QueryOption) ([]model.User, error)
+	List(filter UserFilter, page UserPageRequest) ([]model.User, error)
+	GetByLogin(login string, opts ...QueryOption) (*model.User, error)
+	GetByEmail(email string, opts ...QueryOption) (*model.User, error)
 	Update(user *model.User) error
 	Delete(id uuid.UUID) error
 	ExistsByLogin(login string) (bool, error)
 	ExistsByLoginTx(tx *gorm.DB, login string) (bool, error)
+	ExistsByEmail(email string) (bool, error)
+	ExistsByEmailTx(tx *gorm.DB, email string) (bool, error)
 	HasAdmin() (bool, error)
-	UpdateWithTransaction(user *model.User) error
-	DeleteWithTransaction(Id uuid.UUID) error
+	DeleteWithTransaction(Id uuid.UUID, fn func(tx *gorm.DB, user *model.User) error) error
+	ModifyWithTransaction(id uuid.UUID, fn func(tx *gorm.DB, user *model.User) error) error
 	WithTransaction(fn func(tx *gorm.DB) error) error
 }
 
@@ -33,14 +40,43 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+type queryOptions struct {
+	includeRevoked bool
+}
+
+// QueryOption adjusts the user lookups. Without options revoked users are
+// treated as if they did not exist.
+type QueryOption func(*queryOptions)
+
+// IncludeRevoked makes lookups return revoked users too; meant for admin views.
+func IncludeRevoked() QueryOption {
+	return func(o *queryOptions) {
+		o.includeRevoked = true
+	}
+}
+
+func (r *userRepository) query(opts []QueryOption) *gorm.DB {
+	var o queryOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.includeRevoked {
+		return r.db
+	}
+
+	return r.db.Where("revoked_on IS NULL")
+}
+
 func (r *userRepository) Create(user *model.User) error {
 	return r.db.Create(user).Error
 }
 
-func (r *userRepository) GetById(id uuid.UUID) (*model.User, error) {
+func (r *userRepository) GetById(id uuid.UUID, opts ...QueryOption) (*model.User, error) {
 	var user model.User
 
-	err := r.db.Where("id = ?", id).First(&user).Error
+	err := r.query(opts).Preload("Roles.Permissions").Where("id = ?", id).First(&user).Error
 
 	if err != nil {
 		return wrapNotFound[model.User](err, "User", "id", id.String())
@@ -49,10 +85,162 @@ func (r *userRepository) GetById(id uuid.UUID) (*model.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) GetAll() ([]model.User, error) {
+func (r *userRepository) GetAll(opts ...QueryOption) ([]model.User, error) {
+	var users []model.User
+
+	err := r.query(opts).Find(&users).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+type RevokedFilter int
+
+const (
+	RevokedExclude RevokedFilter = iota
+	RevokedOnly
+	RevokedAll
+)
+
+type UserFilter struct {
+	LoginPrefix string
+	Name        string
+	Gender      *int
+	Admin       *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Revoked     RevokedFilter
+}
+
+// UserCursor points at the last row of the previous page.
+type UserCursor struct {
+	Value string
+	ID    uuid.UUID
+}
+
+type UserPageRequest struct {
+	Sort  string
+	Desc  bool
+	After *UserCursor
+	Limit int
+}
+
+type userSortField struct {
+	column string
+	value  func(*model.User) string
+	parse  func(string) (interface{}, error)
+}
+
+func stringSortValue(s string) (interface{}, error) {
+	return s, nil
+}
+
+func timeSortValue(s string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+var userSortFields = map[string]userSortField{
+	"created_on": {
+		column: "created_on",
+		value:  func(u *model.User) string { return u.CreatedOn.UTC().Format(time.RFC3339Nano) },
+		parse:  timeSortValue,
+	},
+	"modified_on": {
+		column: "modified_on",
+		value:  func(u *model.User) string { return u.ModifiedOn.UTC().Format(time.RFC3339Nano) },
+		parse:  timeSortValue,
+	},
+	"login": {
+		column: "login",
+		value:  func(u *model.User) string { return u.Login },
+		parse:  stringSortValue,
+	},
+	"name": {
+		column: "name",
+		value:  func(u *model.User) string { return u.Name },
+		parse:  stringSortValue,
+	},
+}
+
+// CursorFor returns the keyset position of user under the given sort field.
+func CursorFor(user *model.User, sort string) (UserCursor, error) {
+	field, ok := userSortFields[sort]
+	if !ok {
+		return UserCursor{}, fmt.Errorf("unknown sort field %q", sort)
+	}
+
+	return UserCursor{Value: field.value(user), ID: user.ID}, nil
+}
+
+// List pages through the users with keyset pagination: rows are ordered by the
+// sort column with the ID as tie-breaker, and a page starts strictly after the
+// cursor. Unlike OFFSET this stays fast and stable while rows are inserted.
+func (r *userRepository) List(filter UserFilter, page UserPageRequest) ([]model.User, error) {
+	field, ok := userSortFields[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field %q", page.Sort)
+	}
+
+	q := r.db.Model(&model.User{})
+
+	switch filter.Revoked {
+	case RevokedExclude:
+		q = q.Where("revoked_on IS NULL")
+	case RevokedOnly:
+		q = q.Where("revoked_on IS NOT NULL")
+	}
+
+	if filter.LoginPrefix != "" {
+		q = q.Where("login LIKE ?", escapeLike(filter.LoginPrefix)+"%")
+	}
+
+	if filter.Name != "" {
+		q = q.Where("name LIKE ?", "%"+escapeLike(filter.Name)+"%")
+	}
+
+	if filter.Gender != nil {
+		q = q.Where("gender = ?", *filter.Gender)
+	}
+
+	if filter.Admin != nil {
+		q = q.Where("admin = ?", *filter.Admin)
+	}
+
+	if filter.CreatedFrom != nil {
+		q = q.Where("created_on >= ?", *filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		q = q.Where("created_on < ?", *filter.CreatedTo)
+	}
+
+	direction, op := "ASC", ">"
+	if page.Desc {
+		direction, op = "DESC", "<"
+	}
+
+	if page.After != nil {
+		value, err := field.parse(page.After.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		q = q.Where(
+			fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", field.column, op),
+			value, value, page.After.ID,
+		)
+	}
+
 	var users []model.User
 
-	err := r.db.Find(&users).Error
+	err := q.Preload("Roles").
+		Order(field.column + " " + direction).
+		Order("id " + direction).
+		Limit(page.Limit).
+		Find(&users).Error
 
 	if err != nil {
 		return nil, err
@@ -61,10 +249,16 @@ func (r *userRepository) GetAll() ([]model.User, error) {
 	return users, nil
 }
 
-func (r *userRepository) GetByLogin(login string) (*model.User, error) {
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (r *userRepository) GetByLogin(login string, opts ...QueryOption) (*model.User, error) {
 	var user model.User
 
-	err := r.db.Where("login = ?", login).First(&user).Error
+	err := r.query(opts).Preload("Roles.Permissions").Where("login = ?", login).First(&user).Error
 
 	if err != nil {
 		return wrapNotFound[model.User](err, "User", "login", login)
@@ -73,6 +267,18 @@ func (r *userRepository) GetByLogin(login string) (*model.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) GetByEmail(email string, opts ...QueryOption) (*model.User, error) {
+	var user model.User
+
+	err := r.query(opts).Where("email = ?", email).First(&user).Error
+
+	if err != nil {
+		return wrapNotFound[model.User](err, "User", "email", email)
+	}
+
+	return &user, nil
+}
+
 func (r *userRepository) Update(user *model.User) error {
 	return r.db.Save(user).Error
 }
@@ -123,31 +329,49 @@ func (r *userRepository) ExistsByLogin(login string) (bool, error) {
 	return false, err
 }
 
-func (r *userRepository) UpdateWithTransaction(user *model.User) error {
+func (r *userRepository) ExistsByEmail(email string) (bool, error) {
+	return r.ExistsByEmailTx(r.db, email)
+}
+
+func (r *userRepository) ExistsByEmailTx(tx *gorm.DB, email string) (bool, error) {
+	var user model.User
+
+	err := tx.Select("id").Where("email = ?", email).First(&user).Error
+
+	if err == nil {
+		return true, nil
+	}
+
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// ModifyWithTransaction locks the user row, lets fn change it and saves the result.
+// Associations are left untouched.
+func (r *userRepository) ModifyWithTransaction(id uuid.UUID, fn func(tx *gorm.DB, user *model.User) error) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		var existing model.User
 
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("id = ?", user.ID).
+			Where("id = ?", id).
 			First(&existing).Error; err != nil {
-			return err
+			return wrapNotFoundErr("User", "id", id.String(), err)
 		}
 
-		existing.Name = user.Name
-		existing.Login = user.Login
-		existing.Password = user.Password
-		existing.Gender = user.Gender
-		existing.Admin = user.Admin
-
-		if err := tx.Save(&existing).Error; err != nil {
+		if err := fn(tx, &existing); err != nil {
 			return err
 		}
 
-		return nil
+		return tx.Omit(clause.Associations).Save(&existing).Error
 	})
 }
 
-func (r *userRepository) DeleteWithTransaction(Id uuid.UUID) error {
+// DeleteWithTransaction locks and deletes the user; fn runs in the same
+// transaction before the row is removed.
+func (r *userRepository) DeleteWithTransaction(Id uuid.UUID, fn func(tx *gorm.DB, user *model.User) error) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		var user model.User
 
@@ -157,6 +381,14 @@ func (r *userRepository) DeleteWithTransaction(Id uuid.UUID) error {
 			return wrapNotFoundErr("User", "id", Id.String(), err)
 		}
 
+		if err := fn(tx, &user); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Association("Roles").Clear(); err != nil {
+			return err
+		}
+
 		if err := tx.Delete(&user).Error; err != nil {
 			return err
 		}
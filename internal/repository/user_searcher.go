@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"userapi/internal/model"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserSearcher finds users by login, name and email, best match first.
+// Matching ignores case and accents; revoked users are never returned.
+type UserSearcher interface {
+	Search(ctx context.Context, query string, limit int) ([]model.User, error)
+}
+
+type mysqlUserSearcher struct {
+	db *gorm.DB
+}
+
+// NewMySQLUserSearcher relies on the FULLTEXT index over login, name and email
+// (migration 0002) and on the accent-insensitive utf8mb4_0900_ai_ci collation.
+func NewMySQLUserSearcher(db *gorm.DB) UserSearcher {
+	return &mysqlUserSearcher{db: db}
+}
+
+// minFullTextToken is the InnoDB default innodb_ft_min_token_size. Shorter
+// words are not indexed, so MATCH can never find them.
+const minFullTextToken = 3
+
+func (s *mysqlUserSearcher) Search(ctx context.Context, query string, limit int) ([]model.User, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []model.User{}, nil
+	}
+
+	q := s.db.WithContext(ctx).
+		Preload("Roles").
+		Where("revoked_on IS NULL")
+
+	short := slices.ContainsFunc(terms, func(term string) bool {
+		return utf8.RuneCountInString(term) < minFullTextToken
+	})
+
+	if short {
+		q = s.prefixMatch(q, terms)
+	} else {
+		q = fullTextMatch(q, terms)
+	}
+
+	var users []model.User
+
+	err := q.Limit(limit).Find(&users).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// fullTextMatch uses boolean mode with a trailing * per term for prefix
+// matching, so that "ali" finds "alice"; every term is optional and adds to
+// the relevance. Order ignores gorm.Expr and a later Order call would drop
+// an OrderBy expression, so the whole ordering is one clause.
+func fullTextMatch(q *gorm.DB, terms []string) *gorm.DB {
+	against := strings.Join(terms, "* ") + "*"
+
+	return q.Where("MATCH(login, name, email) AGAINST (? IN BOOLEAN MODE)", against).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "MATCH(login, name, email) AGAINST (? IN BOOLEAN MODE) DESC, login",
+			Vars:               []interface{}{against},
+			WithoutParentheses: true,
+		}})
+}
+
+// prefixMatch is the fallback for terms too short for the full-text index:
+// any term may start the login, the email or a word of the name. It cannot
+// use an index, which the 2 character minimum query length keeps acceptable.
+func (s *mysqlUserSearcher) prefixMatch(q *gorm.DB, terms []string) *gorm.DB {
+	match := s.db.Session(&gorm.Session{NewDB: true})
+
+	for i, term := range terms {
+		prefix := escapeLike(term) + "%"
+		cond := "login LIKE ? OR name LIKE ? OR name LIKE ? OR email LIKE ?"
+		args := []interface{}{prefix, prefix, "% " + prefix, prefix}
+
+		if i == 0 {
+			match = match.Where(cond, args...)
+		} else {
+			match = match.Or(cond, args...)
+		}
+	}
+
+	return q.Where(match).Order("login")
+}
+
+// searchTerms splits text into words the way the full-text parser does, which
+// also drops every character with a meaning in boolean mode syntax.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// MemoryUserSearcher is an in-process UserSearcher for tests. Its ranking
+// approximates the MySQL one: exact word matches beat prefix matches,
+// and a match on the login beats one on name or email.
+type MemoryUserSearcher struct {
+	mu    sync.RWMutex
+	users []model.User
+}
+
+func NewMemoryUserSearcher(users ...model.User) *MemoryUserSearcher {
+	return &MemoryUserSearcher{users: users}
+}
+
+func (s *MemoryUserSearcher) Add(users ...model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = append(s.users, users...)
+}
+
+func (s *MemoryUserSearcher) Search(_ context.Context, query string, limit int) ([]model.User, error) {
+	terms := searchTerms(foldText(query))
+
+	type match struct {
+		user  model.User
+		score int
+	}
+
+	s.mu.RLock()
+	var matches []match
+
+	for _, user := range s.users {
+		if user.RevokedOn != nil {
+			continue
+		}
+
+		if score := memoryScore(user, terms); score > 0 {
+			matches = append(matches, match{user: user, score: score})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+
+		return matches[i].user.Login < matches[j].user.Login
+	})
+
+	users := make([]model.User, 0, min(limit, len(matches)))
+
+	for _, m := range matches {
+		if len(users) == limit {
+			break
+		}
+
+		users = append(users, m.user)
+	}
+
+	return users, nil
+}
+
+type weightedField struct {
+	value  string
+	weight int
+}
+
+func memoryScore(user model.User, terms []string) int {
+	fields := []weightedField{{user.Login, 3}, {user.Name, 2}}
+
+	if user.Email != nil {
+		fields = append(fields, weightedField{*user.Email, 1})
+	}
+
+	score := 0
+
+	for _, term := range terms {
+		for _, field := range fields {
+			for _, word := range searchTerms(foldText(field.value)) {
+				switch {
+				case word == term:
+					score += 2 * field.weight
+				case strings.HasPrefix(word, term):
+					score += field.weight
+				}
+			}
+		}
+	}
+
+	return score
+}
+
+// foldText lower-cases s and strips accents, like the _ai_ci collation does.
+// Transformers keep state, so the chain is built per call.
+func foldText(s string) string {
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+	folded, _, err := transform.String(folder, s)
+	if err != nil {
+		folded = s
+	}
+
+	return strings.ToLower(folded)
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"userapi/internal/migrate"
+	"userapi/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// capturedQuery is the SQL a dry run would have sent to MySQL.
+type capturedQuery struct {
+	sql  string
+	vars []interface{}
+}
+
+// dryRunSearcher builds the MySQL searcher on a connection that never
+// reaches a server and records the statements it would run.
+func dryRunSearcher(t *testing.T) (UserSearcher, *[]capturedQuery) {
+	t.Helper()
+
+	db, err := gorm.Open(
+		mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard},
+	)
+	if err != nil {
+		t.Fatalf("open dry run db: %v", err)
+	}
+
+	var queries []capturedQuery
+
+	err = db.Callback().Query().After("gorm:query").Register("test:capture", func(db *gorm.DB) {
+		queries = append(queries, capturedQuery{sql: db.Statement.SQL.String(), vars: db.Statement.Vars})
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	return NewMySQLUserSearcher(db), &queries
+}
+
+func search(t *testing.T, s UserSearcher, query string, limit int) []model.User {
+	t.Helper()
+
+	users, err := s.Search(context.Background(), query, limit)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+
+	return users
+}
+
+func TestMySQLSearcherUsesFullTextForLongTerms(t *testing.T) {
+	s, queries := dryRunSearcher(t)
+
+	search(t, s, "Ali mar+tin", 10)
+
+	if len(*queries) != 1 {
+		t.Fatalf("ran %d queries, want 1", len(*queries))
+	}
+
+	q := (*queries)[0]
+
+	for _, want := range []string{
+		"revoked_on IS NULL",
+		"MATCH(login, name, email) AGAINST (? IN BOOLEAN MODE)",
+		"ORDER BY MATCH(login, name, email) AGAINST (? IN BOOLEAN MODE) DESC, login LIMIT ?",
+	} {
+		if !strings.Contains(q.sql, want) {
+			t.Errorf("query %q lacks %q", q.sql, want)
+		}
+	}
+
+	if strings.Contains(q.sql, "LIKE") {
+		t.Errorf("query %q falls back to LIKE", q.sql)
+	}
+
+	if got := fmt.Sprint(q.vars[:2]); got != "[Ali* mar* tin* Ali* mar* tin*]" {
+		t.Errorf("AGAINST %s, want boolean mode syntax stripped from the terms", got)
+	}
+}
+
+func TestMySQLSearcherFallsBackToPrefixMatchForShortTerms(t *testing.T) {
+	s, queries := dryRunSearcher(t)
+
+	search(t, s, "li 50%", 10)
+
+	if len(*queries) != 1 {
+		t.Fatalf("ran %d queries, want 1", len(*queries))
+	}
+
+	q := (*queries)[0]
+
+	if !strings.HasSuffix(q.sql, "ORDER BY login LIMIT ?") {
+		t.Errorf("query %q is not ordered by login", q.sql)
+	}
+
+	if strings.Contains(q.sql, "MATCH") {
+		t.Errorf("query %q uses the full-text index for a short term", q.sql)
+	}
+
+	want := "((login LIKE ? OR name LIKE ? OR name LIKE ? OR email LIKE ?) OR (login LIKE ? OR name LIKE ? OR name LIKE ? OR email LIKE ?))"
+	if !strings.Contains(q.sql, want) {
+		t.Errorf("query %q lacks %q", q.sql, want)
+	}
+
+	vars := fmt.Sprint(q.vars[:8])
+	if vars != "[li% li% % li% li% 50% 50% % 50% 50%]" {
+		t.Errorf("LIKE patterns %s", vars)
+	}
+}
+
+func TestMySQLSearcherSkipsEmptyQueries(t *testing.T) {
+	s, queries := dryRunSearcher(t)
+
+	if users := search(t, s, "+-*", 10); len(users) != 0 {
+		t.Errorf("got %d users", len(users))
+	}
+
+	if len(*queries) != 0 {
+		t.Errorf("ran %q for a query without terms", (*queries)[0].sql)
+	}
+}
+
+// TestMySQLSearcher runs against a real server, the only way to check the
+// FULLTEXT index and the collation. USERAPI_TEST_MYSQL_DSN must point to a
+// disposable database; the migrations are applied to it.
+func TestMySQLSearcher(t *testing.T) {
+	dsn := os.Getenv("USERAPI_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("USERAPI_TEST_MYSQL_DSN not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+
+	migrator, err := migrate.New(sqlDB)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	suffix := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	revoked := time.Now()
+
+	users := []model.User{
+		{Login: "alice" + suffix, Name: "Alice Martin"},
+		{Login: "bob" + suffix, Name: "Bob Alicesson"},
+		{Login: "li" + suffix, Name: "Li Wei"},
+		{Login: "gone" + suffix, Name: "Alice Revoked", RevokedOn: &revoked},
+	}
+
+	for i := range users {
+		users[i].ID = uuid.New()
+
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, user := range users {
+			db.Delete(&model.User{}, "id = ?", user.ID)
+		}
+	})
+
+	s := NewMySQLUserSearcher(db)
+
+	logins := func(query string) []string {
+		var found []string
+
+		for _, user := range search(t, s, query, 50) {
+			if strings.HasSuffix(user.Login, suffix) {
+				found = append(found, strings.TrimSuffix(user.Login, suffix))
+			}
+		}
+
+		return found
+	}
+
+	if got := fmt.Sprint(logins("alice" + suffix)); got != "[alice]" {
+		t.Errorf("exact login: got %s", got)
+	}
+
+	if got := fmt.Sprint(logins("ÁLICE")); !strings.HasPrefix(got, "[alice") || strings.Contains(got, "gone") {
+		t.Errorf("accent and case folding: got %s", got)
+	}
+
+	if got := fmt.Sprint(logins("wei")); got != "[li]" {
+		t.Errorf("name word: got %s", got)
+	}
+
+	if got := fmt.Sprint(logins("li")); got != "[li]" {
+		t.Errorf("two character query: got %s", got)
+	}
+}
+
+func memoryLogins(t *testing.T, s *MemoryUserSearcher, query string, limit int) string {
+	t.Helper()
+
+	var logins []string
+	for _, user := range search(t, s, query, limit) {
+		logins = append(logins, user.Login)
+	}
+
+	return fmt.Sprint(logins)
+}
+
+func TestMemoryUserSearcherRanking(t *testing.T) {
+	email := func(s string) *string { return &s }
+	revoked := time.Now()
+
+	s := NewMemoryUserSearcher(
+		model.User{Login: "bob", Name: "Alice Martin", Email: email("bob@example.com")},
+		model.User{Login: "alice", Name: "Alice Durand"},
+		model.User{Login: "alicia", Name: "Alicia Keys"},
+		model.User{Login: "carol", Name: "Carol", Email: email("alice@example.com")},
+		model.User{Login: "alice2", Name: "Alice Gone", RevokedOn: &revoked},
+	)
+
+	// A login match beats a name match, which beats an email match; exact
+	// words beat prefixes and revoked users never show up.
+	if got := memoryLogins(t, s, "alice", 10); got != "[alice bob carol]" {
+		t.Errorf("Search(alice) = %s", got)
+	}
+
+	if got := memoryLogins(t, s, "ali", 10); got != "[alice alicia bob carol]" {
+		t.Errorf("Search(ali) = %s", got)
+	}
+
+	if got := memoryLogins(t, s, "ali", 2); got != "[alice alicia]" {
+		t.Errorf("Search(ali) with limit 2 = %s", got)
+	}
+}
+
+func TestMemoryUserSearcherFoldsCaseAndAccents(t *testing.T) {
+	s := NewMemoryUserSearcher(model.User{Login: "jose", Name: "José Ñúñez"})
+
+	for _, query := range []string{"JOSE", "nunez", "Ñúñ"} {
+		if got := memoryLogins(t, s, query, 10); got != "[jose]" {
+			t.Errorf("Search(%q) = %s", query, got)
+		}
+	}
+}
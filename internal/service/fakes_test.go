@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"net"
+	"sync"
+	"userapi/internal/errors"
+	"userapi/internal/logger"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+// fakeUserRepo keeps users in memory. Transactions run fn on a copy that is
+// stored only when fn succeeds, so a failed fn leaves the user unchanged.
+// Methods the tests do not need panic through the nil embedded interface.
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[uuid.UUID]model.User
+}
+
+func newFakeUserRepo(users ...model.User) *fakeUserRepo {
+	r := &fakeUserRepo{users: map[uuid.UUID]model.User{}}
+
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+
+	return r
+}
+
+func (r *fakeUserRepo) get(id uuid.UUID) model.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.users[id]
+}
+
+func (r *fakeUserRepo) GetById(id uuid.UUID, _ ...repository.QueryOption) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, &errors.NotFoundError{Entity: "User", Field: "id", Value: id.String()}
+	}
+
+	return &user, nil
+}
+
+func (r *fakeUserRepo) GetByLogin(login string, _ ...repository.QueryOption) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Login == login {
+			return &user, nil
+		}
+	}
+
+	return nil, &errors.NotFoundError{Entity: "User", Field: "login", Value: login}
+}
+
+func (r *fakeUserRepo) ExistsByLogin(login string) (bool, error) {
+	_, err := r.GetByLogin(login)
+
+	return err == nil, nil
+}
+
+func (r *fakeUserRepo) ExistsByLoginTx(_ *gorm.DB, login string) (bool, error) {
+	return r.ExistsByLogin(login)
+}
+
+func (r *fakeUserRepo) ExistsByEmail(email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email != nil && *user.Email == email {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeUserRepo) ExistsByEmailTx(_ *gorm.DB, email string) (bool, error) {
+	return r.ExistsByEmail(email)
+}
+
+func (r *fakeUserRepo) ModifyWithTransaction(id uuid.UUID, fn func(tx *gorm.DB, user *model.User) error) error {
+	user, err := r.GetById(id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(nil, user); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.users[id] = *user
+	r.mu.Unlock()
+
+	return nil
+}
+
+type fakeOutbox struct {
+	repository.OutboxRepository
+
+	mu     sync.Mutex
+	events []model.OutboxEvent
+}
+
+func (o *fakeOutbox) AddTx(_ *gorm.DB, event *model.OutboxEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = append(o.events, *event)
+
+	return nil
+}
+
+func (o *fakeOutbox) types() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	types := make([]string, len(o.events))
+	for i, event := range o.events {
+		types[i] = event.EventType
+	}
+
+	return types
+}
+
+// unreachableRedis fails every command at once; the services only log cache
+// invalidation failures.
+func unreachableRedis() *RedisService {
+	return NewRedisClient(redis.NewClient(&redis.Options{
+		Dialer: func(context.Context, string, string) (net.Conn, error) {
+			return nil, stdErrors.New("redis is not available in tests")
+		},
+		MaxRetries: -1,
+	}))
+}
+
+func newTestUserService(repo *fakeUserRepo, outbox *fakeOutbox) *UserService {
+	return &UserService{
+		repo:         repo,
+		outbox:       outbox,
+		redisService: unreachableRedis(),
+	}
+}
+
+type fakeRecoveryCodes struct {
+	repository.RecoveryCodeRepository
+
+	mu     sync.Mutex
+	hashes map[uuid.UUID][]string
+}
+
+func (r *fakeRecoveryCodes) ReplaceTx(_ *gorm.DB, userID uuid.UUID, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hashes == nil {
+		r.hashes = map[uuid.UUID][]string{}
+	}
+
+	r.hashes[userID] = hashes
+
+	return nil
+}
@@ -1,22 +1,57 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"time"
+	"userapi/internal/auth"
+	"userapi/internal/config"
+	"userapi/internal/model"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-func GenerateToken(userID string, isAdmin bool, login string, key []byte) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    isAdmin,
-		"login":   login,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-		"jti":     uuid.New().String(),
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// GenerateToken also returns the claims so callers can track the token's jti and expiry.
+func GenerateToken(user *model.User, grants Grants, sessionID string, keys *KeySet) (string, *auth.Claims, error) {
+	now := time.Now()
+
+	claims := &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			Issuer:    config.GetJwtIssuer(),
+			Audience:  jwt.ClaimStrings{config.GetJwtAudience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.GetJwtExpiration())),
+			ID:        uuid.New().String(),
+		},
+		Login:     user.Login,
+		Roles:     grants.Roles,
+		Scopes:    grants.Scopes,
+		SessionID: sessionID,
+	}
+
+	token, err := keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token, claims, nil
+}
+
+// GenerateOpaqueToken returns a random token for refresh and one-time links; its state lives in Redis.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
 
-	return token.SignedString(key)
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// MaxPasswordLength is the bcrypt input limit. Requests leave the length
+// rules to the policy, so every password path accepts the same passwords.
+const MaxPasswordLength = 72
+
+type PasswordPolicy struct {
+	MinLength    int
+	MaxLength    int
+	RequireUpper bool
+	RequireLower bool
+	RequireDigit bool
+	HistorySize  int
+}
+
+// Check returns field errors keyed by field, in the same shape as UserValidator.
+func (p PasswordPolicy) Check(field, password string) map[string]string {
+	errors := map[string]string{}
+
+	var hasUpper, hasLower, hasDigit bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+
+	switch {
+	case len(password) < p.MinLength:
+		errors[field] = fmt.Sprintf("%s must be at least %d characters", field, p.MinLength)
+	case len(password) > p.MaxLength:
+		errors[field] = fmt.Sprintf("%s must be at most %d characters", field, p.MaxLength)
+	case p.RequireUpper && !hasUpper:
+		errors[field] = fmt.Sprintf("%s must contain an uppercase letter", field)
+	case p.RequireLower && !hasLower:
+		errors[field] = fmt.Sprintf("%s must contain a lowercase letter", field)
+	case p.RequireDigit && !hasDigit:
+		errors[field] = fmt.Sprintf("%s must contain a digit", field)
+	}
+
+	return errors
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
-	"userapi/internal/model"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -37,27 +39,484 @@ func (r *RedisService) IsBlacklisted(ctx context.Context, jti string) (bool, err
 	return result == "true", nil
 }
 
-func (r *RedisService) SetCachedUsers(ctx context.Context, users []model.User) error {
-	data, err := json.Marshal(users)
+// SetCachedUsers stores one listing page. The key carries the cache
+// generation, so bumping the generation drops every cached page at once.
+func (r *RedisService) SetCachedUsers(ctx context.Context, key string, page *UserPage) error {
+	data, err := json.Marshal(page)
 	if err != nil {
 		return err
 	}
 
-	return r.client.Set(ctx, "users", data, 30*time.Minute).Err()
+	return r.client.Set(ctx, key, data, time.Minute).Err()
 }
 
-func (r *RedisService) GetCachedUsers(ctx context.Context) ([]model.User, error) {
-	cache, err := r.client.Get(ctx, "users").Result()
+func (r *RedisService) GetCachedUsers(ctx context.Context, key string) (*UserPage, error) {
+	cache, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
 
-	var users []model.User
-	if err := json.Unmarshal([]byte(cache), &users); err != nil {
+	var page UserPage
+	if err := json.Unmarshal([]byte(cache), &page); err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	return &page, nil
+}
+
+func (r *RedisService) UserCacheGeneration(ctx context.Context) (int64, error) {
+	gen, err := r.client.Get(ctx, "users:generation").Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+
+	return gen, err
+}
+
+func (r *RedisService) BumpUserCacheGeneration(ctx context.Context) error {
+	return r.client.Incr(ctx, "users:generation").Err()
+}
+
+type RefreshToken struct {
+	UserID   string `json:"user_id"`
+	FamilyID string `json:"family_id"`
+}
+
+func (r *RedisService) SaveRefreshToken(ctx context.Context, token string, rt RefreshToken, ttl time.Duration) error {
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, refreshKey(token), data, ttl)
+	pipe.Set(ctx, fmt.Sprintf("refresh_family:%s", rt.FamilyID), rt.UserID, ttl)
+
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *RedisService) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	data, err := r.client.Get(ctx, refreshKey(token)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var rt RefreshToken
+	if err := json.Unmarshal([]byte(data), &rt); err != nil {
+		return nil, err
+	}
+
+	return &rt, nil
+}
+
+// MarkRefreshTokenUsed reports whether this call was the first to use the token.
+// A false result means the token has already been rotated and is being replayed.
+func (r *RedisService) MarkRefreshTokenUsed(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf("refresh_used:%s", hashToken(token))
+
+	return r.client.SetNX(ctx, key, "true", ttl).Result()
+}
+
+func (r *RedisService) IsRefreshFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	key := fmt.Sprintf("refresh_family:%s", familyID)
+	n, err := r.client.Exists(ctx, key).Result()
+
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// RevokeSession ends one session: its refresh family stops rotating and
+// JWTMiddleware rejects the access tokens issued for it.
+func (r *RedisService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf("refresh_family:%s", sessionID))
+	pipe.HDel(ctx, sessionsKey(userID), sessionID)
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// Session is one signed-in device; its ID is the refresh token family ID.
+type Session struct {
+	ID        string    `json:"id"`
+	JTI       string    `json:"jti"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SaveSession stores the session in the per-user hash, replacing the previous
+// entry on refresh. The session being saved is always the newest one, so the
+// hash expires together with it.
+func (r *RedisService) SaveSession(ctx context.Context, userID string, session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	key := sessionsKey(userID)
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, session.ID, data)
+	pipe.Expire(ctx, key, time.Until(session.ExpiresAt))
+
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *RedisService) GetSession(ctx context.Context, userID, sessionID string) (*Session, error) {
+	data, err := r.client.HGet(ctx, sessionsKey(userID), sessionID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &session, nil
+}
+
+func (r *RedisService) SessionExists(ctx context.Context, userID, sessionID string) (bool, error) {
+	return r.client.HExists(ctx, sessionsKey(userID), sessionID).Result()
+}
+
+// ListSessions returns the active sessions and prunes expired ones,
+// since hash fields do not expire on their own.
+func (r *RedisService) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	key := sessionsKey(userID)
+
+	entries, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]Session, 0, len(entries))
+	var expired []string
+
+	for id, data := range entries {
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil || now.After(session.ExpiresAt) {
+			expired = append(expired, id)
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if len(expired) > 0 {
+		if err := r.client.HDel(ctx, key, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, nil
+}
+
+func sessionsKey(userID string) string {
+	return fmt.Sprintf("sessions:%s", userID)
+}
+
+func refreshKey(token string) string {
+	return fmt.Sprintf("refresh:%s", hashToken(token))
+}
+
+// hashToken keeps raw opaque tokens out of Redis keys.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// TrackToken remembers an issued access token so that all tokens of a user
+// can be revoked later without the client presenting them.
+func (r *RedisService) TrackToken(ctx context.Context, userID, jti string, exp time.Time) error {
+	key := fmt.Sprintf("user_tokens:%s", userID)
+
+	pipe := r.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(exp.Unix()), Member: jti})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	pipe.Expire(ctx, key, time.Until(exp))
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *RedisService) TrackRefreshFamily(ctx context.Context, userID, familyID string, ttl time.Duration) error {
+	key := fmt.Sprintf("user_families:%s", userID)
+
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(ctx, key, familyID)
+	pipe.Expire(ctx, key, ttl)
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// SetTokensValidAfter makes every token of the user issued before at invalid.
+// JWT issue times have second precision, so the cut-off is truncated to whole seconds.
+func (r *RedisService) SetTokensValidAfter(ctx context.Context, userID string, at time.Time, ttl time.Duration) error {
+	key := fmt.Sprintf("tokens_valid_after:%s", userID)
+
+	return r.client.Set(ctx, key, at.Unix(), ttl).Err()
+}
+
+// GetTokensValidAfter returns the zero time when no cut-off is set.
+func (r *RedisService) GetTokensValidAfter(ctx context.Context, userID string) (time.Time, error) {
+	key := fmt.Sprintf("tokens_valid_after:%s", userID)
+	seconds, err := r.client.Get(ctx, key).Int64()
+
+	if err == redis.Nil {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(seconds, 0), nil
+}
+
+// SetUserRevoked marks a soft-deleted account; the marker has no TTL and is
+// only removed by ClearUserRevoked.
+func (r *RedisService) SetUserRevoked(ctx context.Context, userID string) error {
+	key := fmt.Sprintf("user_revoked:%s", userID)
+
+	return r.client.Set(ctx, key, "true", 0).Err()
+}
+
+func (r *RedisService) ClearUserRevoked(ctx context.Context, userID string) error {
+	key := fmt.Sprintf("user_revoked:%s", userID)
+
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *RedisService) IsUserRevoked(ctx context.Context, userID string) (bool, error) {
+	key := fmt.Sprintf("user_revoked:%s", userID)
+	n, err := r.client.Exists(ctx, key).Result()
+
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// RevokeUserTokens blacklists every tracked, unexpired access token of the user
+// and revokes all of their refresh token families and sessions.
+func (r *RedisService) RevokeUserTokens(ctx context.Context, userID string) error {
+	tokensKey := fmt.Sprintf("user_tokens:%s", userID)
+	familiesKey := fmt.Sprintf("user_families:%s", userID)
+	now := time.Now()
+
+	tokens, err := r.client.ZRangeByScoreWithScores(ctx, tokensKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	families, err := r.client.SMembers(ctx, familiesKey).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+
+	for _, t := range tokens {
+		ttl := time.Unix(int64(t.Score), 0).Sub(now)
+		pipe.Set(ctx, fmt.Sprintf("blacklist:%s", t.Member), "true", ttl)
+	}
+
+	for _, family := range families {
+		pipe.Del(ctx, fmt.Sprintf("refresh_family:%s", family))
+	}
+
+	pipe.Del(ctx, tokensKey, familiesKey, sessionsKey(userID))
+
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *RedisService) SaveResetToken(ctx context.Context, token, userID string, ttl time.Duration) error {
+	key := fmt.Sprintf("password_reset:%s", hashToken(token))
+
+	return r.client.Set(ctx, key, userID, ttl).Err()
+}
+
+// GetResetToken returns the user ID of a reset token without using it up.
+// An empty ID means unknown or expired.
+func (r *RedisService) GetResetToken(ctx context.Context, token string) (string, error) {
+	key := fmt.Sprintf("password_reset:%s", hashToken(token))
+	userID, err := r.client.Get(ctx, key).Result()
+
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// ConsumeResetToken returns the user ID of a reset token and deletes it atomically,
+// so a token can never be used twice. An empty ID means unknown or expired.
+func (r *RedisService) ConsumeResetToken(ctx context.Context, token string) (string, error) {
+	key := fmt.Sprintf("password_reset:%s", hashToken(token))
+	userID, err := r.client.GetDel(ctx, key).Result()
+
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+type EmailVerification struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+func (r *RedisService) SaveEmailVerification(ctx context.Context, token string, v EmailVerification, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("email_verify:%s", hashToken(token))
+
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+// ConsumeEmailVerification deletes the token while reading it; nil means unknown or expired.
+func (r *RedisService) ConsumeEmailVerification(ctx context.Context, token string) (*EmailVerification, error) {
+	key := fmt.Sprintf("email_verify:%s", hashToken(token))
+	data, err := r.client.GetDel(ctx, key).Result()
+
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var v EmailVerification
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+func (r *RedisService) SaveLoginChallenge(ctx context.Context, token, userID string, ttl time.Duration) error {
+	key := fmt.Sprintf("2fa_challenge:%s", hashToken(token))
+
+	return r.client.Set(ctx, key, userID, ttl).Err()
+}
+
+// PeekLoginChallenge returns the user ID of a pending 2FA challenge without
+// counting an attempt. An empty ID means unknown or expired.
+func (r *RedisService) PeekLoginChallenge(ctx context.Context, token string) (string, error) {
+	userID, err := r.client.Get(ctx, fmt.Sprintf("2fa_challenge:%s", hashToken(token))).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+
+	return userID, err
+}
+
+// GetLoginChallenge returns the user ID of a pending 2FA challenge and counts the attempt.
+func (r *RedisService) GetLoginChallenge(ctx context.Context, token string) (string, int64, error) {
+	key := fmt.Sprintf("2fa_challenge:%s", hashToken(token))
+	attemptsKey := fmt.Sprintf("2fa_attempts:%s", hashToken(token))
+
+	userID, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", 0, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+
+	pipe := r.client.TxPipeline()
+	attempts := pipe.Incr(ctx, attemptsKey)
+	pipe.Expire(ctx, attemptsKey, 10*time.Minute)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", 0, err
+	}
+
+	return userID, attempts.Val(), nil
+}
+
+func (r *RedisService) DeleteLoginChallenge(ctx context.Context, token string) error {
+	return r.client.Del(ctx,
+		fmt.Sprintf("2fa_challenge:%s", hashToken(token)),
+		fmt.Sprintf("2fa_attempts:%s", hashToken(token)),
+	).Err()
+}
+
+// MarkTOTPStepUsed reports whether the code of this time step is used for the first time.
+func (r *RedisService) MarkTOTPStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	key := fmt.Sprintf("totp_used:%s:%d", userID, step)
+
+	return r.client.SetNX(ctx, key, "true", 2*time.Minute).Result()
+}
+
+// IncrementCounter bumps a failure counter and (re)starts its window.
+func (r *RedisService) IncrementCounter(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return count.Val(), nil
+}
+
+func (r *RedisService) SetLock(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, "true", ttl).Err()
+}
+
+// LockTTL returns how long the lock still holds, or zero when there is none.
+func (r *RedisService) LockTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (r *RedisService) Delete(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
 }
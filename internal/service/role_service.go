@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+	"userapi/internal/auth"
+	"userapi/internal/config"
+	"userapi/internal/errors"
+	"userapi/internal/kafka"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Grants are the roles and permissions copied into an access token.
+type Grants struct {
+	Roles  []string
+	Scopes []string
+}
+
+type RoleService struct {
+	repo         repository.RoleRepository
+	outbox       repository.OutboxRepository
+	redisService *RedisService
+}
+
+func NewRoleService(repo repository.RoleRepository, outbox repository.OutboxRepository, redisService *RedisService) *RoleService {
+	return &RoleService{repo: repo, outbox: outbox, redisService: redisService}
+}
+
+var defaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{auth.RoleAdmin, "Full administrative access", nil},
+	{auth.RoleSupport, "Read-only access to user accounts", []string{auth.PermUsersRead}},
+	{auth.RoleUser, "Regular self-service user", []string{}},
+}
+
+// EnsureDefaultRoles seeds the permission catalogue and the built-in roles.
+// The admin role is always reset to hold every known permission.
+func (s *RoleService) EnsureDefaultRoles() error {
+	all := make([]string, 0, len(auth.Permissions))
+
+	for name, description := range auth.Permissions {
+		if err := s.repo.EnsurePermission(&model.Permission{Name: name, Description: description}); err != nil {
+			return err
+		}
+
+		all = append(all, name)
+	}
+
+	for _, def := range defaultRoles {
+		role := model.Role{Name: def.Name, Description: def.Description}
+
+		if err := s.repo.EnsureRole(&role); err != nil {
+			return err
+		}
+
+		names := def.Permissions
+
+		if def.Name == auth.RoleAdmin {
+			names = all
+		} else if len(role.Permissions) > 0 || len(names) == 0 {
+			continue
+		}
+
+		permissions, err := s.repo.GetPermissionsByNames(names)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.ReplacePermissions(&role, permissions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RoleService) GetAll() ([]model.Role, error) {
+	return s.repo.GetAll()
+}
+
+func (s *RoleService) GetPermissions() ([]model.Permission, error) {
+	return s.repo.GetPermissions()
+}
+
+func (s *RoleService) Create(role model.Role, permissionNames []string) error {
+	if _, err := s.repo.GetByName(role.Name); err == nil {
+		return &errors.ConflictError{Field: "role", Value: role.Name}
+	}
+
+	permissions, err := s.resolvePermissions(permissionNames)
+	if err != nil {
+		return err
+	}
+
+	role.Permissions = permissions
+
+	return s.repo.Create(&role)
+}
+
+func (s *RoleService) SetPermissions(name string, permissionNames []string) error {
+	if name == auth.RoleAdmin {
+		return &errors.ValidationError{Fields: map[string]string{"role": "built-in admin role cannot be changed"}}
+	}
+
+	role, err := s.repo.GetByName(name)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := s.resolvePermissions(permissionNames)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ReplacePermissions(role, permissions)
+}
+
+func (s *RoleService) Delete(name string) error {
+	if isDefaultRole(name) {
+		return &errors.ValidationError{Fields: map[string]string{"role": "built-in roles cannot be deleted"}}
+	}
+
+	return s.repo.Delete(name)
+}
+
+func (s *RoleService) AssignToUser(ctx context.Context, userID uuid.UUID, name string) error {
+	role, err := s.repo.GetByName(name)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.AssignToUser(userID, role, s.recordRoleChange(ctx, userID, role, kafka.RoleAssigned))
+}
+
+// RemoveFromUser invalidates the user's tokens, which still carry the removed role.
+func (s *RoleService) RemoveFromUser(ctx context.Context, userID uuid.UUID, name string) error {
+	role, err := s.repo.GetByName(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveFromUser(userID, role, s.recordRoleChange(ctx, userID, role, kafka.RoleRemoved)); err != nil {
+		return err
+	}
+
+	return s.redisService.SetTokensValidAfter(ctx, userID.String(), time.Now(), config.GetRefreshExpiration())
+}
+
+func (s *RoleService) recordRoleChange(ctx context.Context, userID uuid.UUID, role *model.Role, action string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return recordEventTx(ctx, s.outbox, tx, kafka.UserRoleChangedEvent{
+			UserID: userID.String(),
+			Role:   role.Name,
+			Action: action,
+		})
+	}
+}
+
+// GrantsFor expects user.Roles to be preloaded with permissions.
+// The legacy Admin flag is honoured as membership in the admin role.
+func (s *RoleService) GrantsFor(user *model.User) (Grants, error) {
+	roles := user.Roles
+
+	if user.Admin && !slices.ContainsFunc(roles, func(r model.Role) bool { return r.Name == auth.RoleAdmin }) {
+		admin, err := s.repo.GetByName(auth.RoleAdmin)
+		if err != nil {
+			return Grants{}, err
+		}
+
+		roles = append(roles, *admin)
+	}
+
+	grants := Grants{Roles: []string{auth.RoleUser}}
+
+	for _, role := range roles {
+		if !slices.Contains(grants.Roles, role.Name) {
+			grants.Roles = append(grants.Roles, role.Name)
+		}
+
+		for _, p := range role.Permissions {
+			if !slices.Contains(grants.Scopes, p.Name) {
+				grants.Scopes = append(grants.Scopes, p.Name)
+			}
+		}
+	}
+
+	sort.Strings(grants.Scopes)
+
+	return grants, nil
+}
+
+func (s *RoleService) resolvePermissions(names []string) ([]model.Permission, error) {
+	permissions, err := s.repo.GetPermissionsByNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(permissions, func(p model.Permission) bool { return p.Name == name }) {
+			return nil, &errors.ValidationError{Fields: map[string]string{"permissions": fmt.Sprintf("unknown permission %s", name)}}
+		}
+	}
+
+	return permissions, nil
+}
+
+func isDefaultRole(name string) bool {
+	for _, def := range defaultRoles {
+		if def.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks an encrypted value. Values without it are plaintext
+// written before encryption was introduced; ':' never occurs in base32.
+const sealedPrefix = "v1:"
+
+// SecretBox encrypts secrets that must be read back, such as TOTP secrets,
+// with AES-256-GCM. The owner ID is bound as additional data, so a value
+// copied to another user's row does not decrypt.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+func (b *SecretBox) Seal(owner, plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(owner))
+
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open returns legacy plaintext values unchanged.
+func (b *SecretBox) Open(owner, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", fmt.Errorf("malformed sealed secret")
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(owner))
+	if err != nil {
+		return "", fmt.Errorf("open sealed secret: %w", err)
+	}
+
+	return string(plaintext), nil
+}
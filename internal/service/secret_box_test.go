@@ -0,0 +1,68 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newTestSecretBox(t *testing.T) *SecretBox {
+	t.Helper()
+
+	box, err := NewSecretBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewSecretBox: %v", err)
+	}
+
+	return box
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box := newTestSecretBox(t)
+
+	sealed, err := box.Seal("user-1", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if !strings.HasPrefix(sealed, sealedPrefix) || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("sealed value %q is not encrypted", sealed)
+	}
+
+	if len(sealed) > 255 {
+		t.Fatalf("sealed value is %d characters, the column holds 255", len(sealed))
+	}
+
+	opened, err := box.Open("user-1", sealed)
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+}
+
+func TestSecretBoxRejectsOtherOwnerAndTampering(t *testing.T) {
+	box := newTestSecretBox(t)
+
+	sealed, _ := box.Seal("user-1", "JBSWY3DPEHPK3PXP")
+
+	if _, err := box.Open("user-2", sealed); err == nil {
+		t.Error("value opened for another owner")
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+
+	if _, err := box.Open("user-1", tampered); err == nil {
+		t.Error("tampered value opened")
+	}
+}
+
+func TestSecretBoxOpensLegacyPlaintext(t *testing.T) {
+	box := newTestSecretBox(t)
+
+	opened, err := box.Open("user-1", "JBSWY3DPEHPK3PXP")
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+}
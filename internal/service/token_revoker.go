@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"time"
+	"userapi/internal/auth"
+	"userapi/internal/config"
+)
+
+// TokenRevoker ends access tokens and the sessions behind them, for logout
+// and for the RFC 7009 revocation endpoint.
+type TokenRevoker struct {
+	validator    *TokenValidator
+	redisService *RedisService
+}
+
+func NewTokenRevoker(validator *TokenValidator, redisService *RedisService) *TokenRevoker {
+	return &TokenRevoker{validator: validator, redisService: redisService}
+}
+
+// Logout blacklists the presented access token until its own expiry and
+// revokes the session it belongs to, so the linked refresh token stops working.
+func (r *TokenRevoker) Logout(ctx context.Context, claims *auth.Claims) error {
+	if err := r.blacklist(ctx, claims); err != nil {
+		return err
+	}
+
+	return r.redisService.RevokeSession(ctx, claims.Subject, claims.SessionID)
+}
+
+// Revoke implements RFC 7009: the hint only decides which token type is tried
+// first, and unknown, expired or already revoked tokens are not an error.
+// Revoking a refresh token ends its whole session including the access tokens;
+// revoking an access token leaves the session alone.
+func (r *TokenRevoker) Revoke(ctx context.Context, token, hint string) error {
+	first, second := r.revokeAccess, r.revokeRefresh
+
+	if hint == "refresh_token" {
+		first, second = second, first
+	}
+
+	revoked, err := first(ctx, token)
+	if err != nil || revoked {
+		return err
+	}
+
+	_, err = second(ctx, token)
+
+	return err
+}
+
+func (r *TokenRevoker) revokeAccess(ctx context.Context, token string) (bool, error) {
+	claims, err := r.validator.Validate(token)
+	if err != nil {
+		return false, nil
+	}
+
+	return true, r.blacklist(ctx, claims)
+}
+
+func (r *TokenRevoker) revokeRefresh(ctx context.Context, token string) (bool, error) {
+	rt, err := r.redisService.GetRefreshToken(ctx, token)
+	if err != nil || rt == nil {
+		return false, err
+	}
+
+	return true, r.redisService.RevokeSession(ctx, rt.UserID, rt.FamilyID)
+}
+
+// blacklist keeps the entry for the validator's leeway past exp, since the
+// token is still accepted during that time.
+func (r *TokenRevoker) blacklist(ctx context.Context, claims *auth.Claims) error {
+	ttl := time.Until(claims.ExpiresAt.Time) + config.GetJwtLeeway()
+
+	if ttl <= 0 {
+		return nil
+	}
+
+	return r.redisService.SetToBlacklist(ctx, claims.ID, ttl)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"userapi/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenMalformed   = errors.New("malformed token")
+	ErrTokenAlgorithm   = errors.New("signing algorithm not allowed")
+	ErrTokenUnknownKey  = errors.New("unknown signing key")
+	ErrTokenSignature   = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not valid yet")
+	ErrTokenIssuer      = errors.New("invalid token issuer")
+	ErrTokenAudience    = errors.New("invalid token audience")
+	ErrTokenClaims      = errors.New("invalid token claims")
+	ErrTokenInvalid     = errors.New("invalid token")
+)
+
+type TokenValidatorConfig struct {
+	Algorithms []string
+	Issuer     string
+	Audience   string
+	Leeway     time.Duration
+}
+
+type TokenValidator struct {
+	keys       *KeySet
+	algorithms []string
+	parser     *jwt.Parser
+}
+
+func NewTokenValidator(keys *KeySet, cfg TokenValidatorConfig) *TokenValidator {
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = keys.Algorithms()
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	return &TokenValidator{
+		keys:       keys,
+		algorithms: algorithms,
+		parser:     parser,
+	}
+}
+
+func (v *TokenValidator) Validate(tokenStr string) (*auth.Claims, error) {
+	claims := &auth.Claims{}
+
+	// The algorithm is pinned inside the keyfunc rather than with jwt.WithValidMethods,
+	// because the parser reports a rejected method as a plain signature error.
+	_, err := v.parser.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		if !slices.Contains(v.algorithms, t.Method.Alg()) {
+			return nil, fmt.Errorf("%w: %s", ErrTokenAlgorithm, t.Method.Alg())
+		}
+
+		return v.keys.Keyfunc(t)
+	})
+
+	if err != nil {
+		return nil, classifyTokenError(err)
+	}
+
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, ErrTokenClaims
+	}
+
+	return claims, nil
+}
+
+func classifyTokenError(err error) error {
+	switch {
+	case errors.Is(err, ErrTokenAlgorithm):
+		return ErrTokenAlgorithm
+	case errors.Is(err, ErrTokenUnknownKey):
+		return ErrTokenUnknownKey
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return ErrTokenSignature
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return ErrTokenClaims
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenAudience
+	default:
+		return ErrTokenInvalid
+	}
+}
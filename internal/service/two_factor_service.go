@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+	"userapi/internal/auth"
+	"userapi/internal/config"
+	"userapi/internal/errors"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorService struct {
+	repo         repository.UserRepository
+	recovery     repository.RecoveryCodeRepository
+	redisService *RedisService
+	secrets      *SecretBox
+}
+
+// NewTwoFactorService stores the TOTP secrets encrypted with secrets.
+func NewTwoFactorService(
+	repo repository.UserRepository,
+	recovery repository.RecoveryCodeRepository,
+	redisService *RedisService,
+	secrets *SecretBox,
+) *TwoFactorService {
+	return &TwoFactorService{
+		repo:         repo,
+		recovery:     recovery,
+		redisService: redisService,
+		secrets:      secrets,
+	}
+}
+
+// Enroll stores a new pending secret; 2FA is only enforced after Confirm.
+// Enrolling again before confirmation replaces the pending secret.
+func (s *TwoFactorService) Enroll(id uuid.UUID) (*TOTPEnrollment, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(code)
+	}
+
+	var login string
+
+	err = s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if user.TOTPEnabled {
+			return &errors.ConflictError{Field: "two-factor authentication", Value: "enabled"}
+		}
+
+		if err := s.recovery.ReplaceTx(tx, user.ID, hashes); err != nil {
+			return err
+		}
+
+		sealed, err := s.secrets.Seal(user.ID.String(), secret)
+		if err != nil {
+			return err
+		}
+
+		user.TOTPSecret = &sealed
+		login = user.Login
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:        secret,
+		URI:           TOTPURI(config.GetJwtIssuer(), login, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (s *TwoFactorService) Confirm(ctx context.Context, id uuid.UUID, code string) error {
+	return s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if user.TOTPEnabled {
+			return &errors.ConflictError{Field: "two-factor authentication", Value: "enabled"}
+		}
+
+		if user.TOTPSecret == nil {
+			return &errors.ValidationError{Fields: map[string]string{"code": "start enrollment first"}}
+		}
+
+		ok, err := s.verifyTOTP(ctx, user, code)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return &errors.ValidationError{Fields: map[string]string{"code": "invalid code"}}
+		}
+
+		user.TOTPEnabled = true
+
+		return nil
+	})
+}
+
+// Disable requires both the password and a second factor. Admins cannot
+// disable 2FA while the admin 2FA policy is on.
+func (s *TwoFactorService) Disable(ctx context.Context, id uuid.UUID, password, code string, isAdmin bool) error {
+	if isAdmin && config.RequireAdmin2FA() {
+		return &errors.ForbiddenError{Reason: "two-factor authentication is required for admins"}
+	}
+
+	return s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if !user.TOTPEnabled {
+			return &errors.ValidationError{Fields: map[string]string{"code": "two-factor authentication is not enabled"}}
+		}
+
+		if !CheckPassword(user.Password, password) {
+			return &errors.UnauthorizedError{Reason: "invalid credentials"}
+		}
+
+		ok, err := s.verifyTx(ctx, tx, user, code)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return &errors.UnauthorizedError{Reason: "invalid code"}
+		}
+
+		if err := s.recovery.DeleteAllTx(tx, user.ID); err != nil {
+			return err
+		}
+
+		user.TOTPSecret = nil
+		user.TOTPEnabled = false
+
+		return nil
+	})
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code.
+func (s *TwoFactorService) Verify(ctx context.Context, user *model.User, code string) (bool, error) {
+	ok, err := s.verifyTOTP(ctx, user, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return s.recovery.Use(user.ID, hashToken(code))
+}
+
+// verifyTx is Verify inside a transaction: a recovery code is only used up
+// if tx commits.
+func (s *TwoFactorService) verifyTx(ctx context.Context, tx *gorm.DB, user *model.User, code string) (bool, error) {
+	ok, err := s.verifyTOTP(ctx, user, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return s.recovery.UseTx(tx, user.ID, hashToken(code))
+}
+
+// EnrollmentRequired reports whether an admin must enrol in 2FA before getting admin rights.
+func (s *TwoFactorService) EnrollmentRequired(user *model.User, grants Grants) bool {
+	return config.RequireAdmin2FA() && !user.TOTPEnabled && slices.Contains(grants.Roles, auth.RoleAdmin)
+}
+
+func (s *TwoFactorService) verifyTOTP(ctx context.Context, user *model.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	secret, err := s.secrets.Open(user.ID.String(), *user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return s.redisService.MarkTOTPStepUsed(ctx, user.ID.String(), step)
+}
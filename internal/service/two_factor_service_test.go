@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestEnrollStoresTheSecretEncrypted(t *testing.T) {
+	user := testUser("bob", false)
+	repo := newFakeUserRepo(user)
+	secrets := newTestSecretBox(t)
+	s := NewTwoFactorService(repo, &fakeRecoveryCodes{}, nil, secrets)
+
+	enrollment, err := s.Enroll(user.ID)
+	if err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+
+	stored := repo.get(user.ID).TOTPSecret
+	if stored == nil || *stored == enrollment.Secret {
+		t.Fatalf("stored secret %v is not encrypted", stored)
+	}
+
+	opened, err := secrets.Open(user.ID.String(), *stored)
+	if err != nil || opened != enrollment.Secret {
+		t.Fatalf("Open = %q, %v, want the enrolled secret", opened, err)
+	}
+}
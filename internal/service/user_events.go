@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"userapi/internal/kafka"
+	"userapi/internal/logger"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// recordEventTx stores the event in the outbox within tx, so it is published
+// if and only if the change it describes is committed.
+func recordEventTx(ctx context.Context, outbox repository.OutboxRepository, tx *gorm.DB, event kafka.Event) error {
+	envelope, err := kafka.NewEnvelope(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	return outbox.AddTx(tx, &model.OutboxEvent{
+		AggregateType: "user",
+		AggregateID:   event.Key(),
+		EventType:     envelope.Type,
+		Payload:       body,
+		CreatedOn:     now,
+		NextAttemptOn: now,
+	})
+}
+
+func (s *UserService) recordEventTx(ctx context.Context, tx *gorm.DB, event kafka.Event) error {
+	return recordEventTx(ctx, s.outbox, tx, event)
+}
+
+// recordUpdateTx records user.updated for the changed fields. Nothing is
+// recorded when nothing changed. A password change is recorded on its own by
+// setPasswordTx.
+func (s *UserService) recordUpdateTx(ctx context.Context, tx *gorm.DB, user *model.User, changed []string) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return s.recordEventTx(ctx, tx, kafka.UserUpdatedEvent{
+		UserID:        user.ID.String(),
+		Login:         user.Login,
+		ChangedFields: changed,
+	})
+}
+
+// recordLogin records user.logged_in. The tokens are already issued, so a
+// failure is only logged instead of failing the login.
+func (s *UserService) recordLogin(ctx context.Context, user *model.User, sessionID, method string, client ClientInfo) {
+	ctx = kafka.WithActor(ctx, user.Login)
+
+	err := s.repo.WithTransaction(func(tx *gorm.DB) error {
+		return s.recordEventTx(ctx, tx, kafka.UserLoggedInEvent{
+			UserID:    user.ID.String(),
+			Login:     user.Login,
+			SessionID: sessionID,
+			Method:    method,
+			IP:        client.IP,
+			UserAgent: client.UserAgent,
+		})
+	})
+
+	if err != nil {
+		logger.Log.Warn("failed to record login event", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"userapi/internal/contract"
+	"userapi/internal/dto"
+	"userapi/internal/errors"
+	"userapi/internal/logger"
+	"userapi/internal/model"
+	"userapi/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// cacheWriteTimeout bounds the background cache write, so shutdown never
+// waits on a stuck Redis for long.
+const cacheWriteTimeout = 2 * time.Second
+
+// UserPage holds admin views rather than models, so cached pages never
+// contain password hashes.
+type UserPage struct {
+	Users      []dto.AdminUserResponse `json:"users"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	HasMore    bool                    `json:"has_more"`
+}
+
+// cursorPayload is opaque to clients; it remembers the sort it was issued
+// for so it cannot be replayed against a different ordering.
+type cursorPayload struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// GetAll returns one page of the admin user listing. Pages are cached
+// briefly, keyed by the normalized query.
+func (s *UserService) GetAll(ctx context.Context, q dto.UserListQuery) (*UserPage, error) {
+	q.Normalize()
+
+	cacheKey, err := s.userCacheKey(ctx, q)
+	if err != nil {
+		logger.Log.Warn("user cache unavailable", zap.Error(err))
+	}
+
+	if cacheKey != "" {
+		if page, err := s.redisService.GetCachedUsers(ctx, cacheKey); err == nil && page != nil {
+			return page, nil
+		}
+	}
+
+	filter, page, err := listRequest(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// one extra row tells whether there is a next page
+	page.Limit = q.Limit + 1
+
+	users, err := s.repo.List(filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UserPage{}
+
+	if len(users) > q.Limit {
+		users = users[:q.Limit]
+		result.HasMore = true
+
+		result.NextCursor, err = encodeCursor(&users[q.Limit-1], page.Sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Users = contract.ToAdminUsers(users)
+
+	if cacheKey != "" {
+		s.tasks.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cacheWriteTimeout)
+			defer cancel()
+
+			_ = s.redisService.SetCachedUsers(ctx, cacheKey, result)
+		})
+	}
+
+	return result, nil
+}
+
+func listRequest(q dto.UserListQuery) (repository.UserFilter, repository.UserPageRequest, error) {
+	filter := repository.UserFilter{
+		LoginPrefix: q.Login,
+		Name:        q.Name,
+		Gender:      q.Gender,
+		Admin:       q.Admin,
+		CreatedFrom: q.CreatedFrom,
+		CreatedTo:   q.CreatedTo,
+	}
+
+	switch q.Revoked {
+	case "true":
+		filter.Revoked = repository.RevokedOnly
+	case "all":
+		filter.Revoked = repository.RevokedAll
+	}
+
+	page := repository.UserPageRequest{
+		Sort: strings.TrimPrefix(q.Sort, "-"),
+		Desc: strings.HasPrefix(q.Sort, "-"),
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor, page.Sort)
+		if err != nil {
+			return filter, page, &errors.ValidationError{Fields: map[string]string{"cursor": "invalid cursor"}}
+		}
+
+		page.After = cursor
+	}
+
+	return filter, page, nil
+}
+
+func encodeCursor(last *model.User, sort string) (string, error) {
+	cursor, err := repository.CursorFor(last, sort)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(cursorPayload{Sort: sort, Value: cursor.Value, ID: cursor.ID.String()})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded, sort string) (*repository.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Sort != sort {
+		return nil, fmt.Errorf("cursor issued for sort %q", payload.Sort)
+	}
+
+	id, err := uuid.Parse(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.UserCursor{Value: payload.Value, ID: id}, nil
+}
+
+func (s *UserService) userCacheKey(ctx context.Context, q dto.UserListQuery) (string, error) {
+	gen, err := s.redisService.UserCacheGeneration(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return fmt.Sprintf("users:%d:%s", gen, hex.EncodeToString(sum[:16])), nil
+}
+
+// invalidateUserCache drops every cached listing page. A failure only leaves
+// pages stale until they expire, so it is logged rather than returned.
+func (s *UserService) invalidateUserCache(ctx context.Context) {
+	if err := s.redisService.BumpUserCacheGeneration(ctx); err != nil {
+		logger.Log.Warn("failed to invalidate user cache", zap.Error(err))
+	}
+}
+
+// Search returns the best matching active users for an admin lookup.
+func (s *UserService) Search(ctx context.Context, q dto.UserSearchQuery) ([]model.User, error) {
+	if q.Limit == 0 {
+		q.Limit = dto.DefaultUserSearchLimit
+	}
+
+	return s.searcher.Search(ctx, q.Q, q.Limit)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"userapi/internal/dto"
+	"userapi/internal/model"
+)
+
+type limitRecorder struct {
+	limit int
+}
+
+func (r *limitRecorder) Search(_ context.Context, _ string, limit int) ([]model.User, error) {
+	r.limit = limit
+
+	return nil, nil
+}
+
+func TestSearchDefaultsTheLimit(t *testing.T) {
+	searcher := &limitRecorder{}
+	s := &UserService{searcher: searcher}
+
+	if _, err := s.Search(context.Background(), dto.UserSearchQuery{Q: "al"}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if searcher.limit != dto.DefaultUserSearchLimit {
+		t.Errorf("limit = %d, want %d", searcher.limit, dto.DefaultUserSearchLimit)
+	}
+
+	if _, err := s.Search(context.Background(), dto.UserSearchQuery{Q: "al", Limit: 5}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if searcher.limit != 5 {
+		t.Errorf("limit = %d, want 5", searcher.limit)
+	}
+}
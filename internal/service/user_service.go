@@ -2,38 +2,89 @@ package service
 
 import (
 	"context"
+	stdErrors "errors"
+	"fmt"
+	"slices"
+	"time"
+	"userapi/internal/auth"
+	"userapi/internal/config"
+	"userapi/internal/dto"
+	"userapi/internal/kafka"
+	"userapi/internal/lifecycle"
+	"userapi/internal/logger"
+	"userapi/internal/mail"
 	"userapi/internal/model"
 	"userapi/internal/repository"
 
 	"userapi/internal/errors"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserService struct {
 	repo         repository.UserRepository
+	searcher     repository.UserSearcher
+	history      repository.PasswordHistoryRepository
+	outbox       repository.OutboxRepository
+	roles        *RoleService
+	twoFactor    *TwoFactorService
+	policy       PasswordPolicy
+	mailer       mail.Mailer
 	redisService *RedisService
-	jwtKey       []byte
+	keys         *KeySet
+	tasks        *lifecycle.Tasks
 }
 
-func NewUserService(repo repository.UserRepository, redisService *RedisService, jwtKey []byte) *UserService {
+func NewUserService(
+	repo repository.UserRepository,
+	searcher repository.UserSearcher,
+	history repository.PasswordHistoryRepository,
+	outbox repository.OutboxRepository,
+	roles *RoleService,
+	twoFactor *TwoFactorService,
+	policy PasswordPolicy,
+	mailer mail.Mailer,
+	redisService *RedisService,
+	keys *KeySet,
+	tasks *lifecycle.Tasks,
+) *UserService {
 	return &UserService{
 		repo:         repo,
-		jwtKey:       jwtKey,
+		searcher:     searcher,
+		history:      history,
+		outbox:       outbox,
+		roles:        roles,
+		twoFactor:    twoFactor,
+		policy:       policy,
+		mailer:       mailer,
+		keys:         keys,
 		redisService: redisService,
+		tasks:        tasks,
 	}
 }
 
-func (s *UserService) Register(user model.User) error {
-	hashedPassword, err := HashPassword(user.Password)
+// RegisterAdmin is the admin registration; only a caller that may grant the
+// admin role can create an admin.
+func (s *UserService) RegisterAdmin(ctx context.Context, user model.User, canGrantAdmin bool) error {
+	if user.Admin && !canGrantAdmin {
+		return adminFlagForbidden()
+	}
+
+	return s.Register(ctx, user)
+}
+
+// Register applies the password policy like every other password change.
+func (s *UserService) Register(ctx context.Context, user model.User) error {
+	hashedPassword, err := s.hashNewPassword("password", user.Password)
 	if err != nil {
 		return err
 	}
 	user.Password = hashedPassword
 
-	return s.repo.WithTransaction(func(tx *gorm.DB) error {
+	err = s.repo.WithTransaction(func(tx *gorm.DB) error {
 		exists, err := s.repo.ExistsByLoginTx(tx, user.Login)
 		if err != nil {
 			return err
@@ -43,22 +94,430 @@ func (s *UserService) Register(user model.User) error {
 			return &errors.ConflictError{Field: "login", Value: user.Login}
 		}
 
-		return tx.Create(&user).Error
+		if user.Email != nil {
+			exists, err := s.repo.ExistsByEmailTx(tx, *user.Email)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return &errors.ConflictError{Field: "email", Value: *user.Email}
+			}
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		event := kafka.UserRegisteredEvent{UserID: user.ID.String(), Login: user.Login}
+		if user.Email != nil {
+			event.Email = *user.Email
+		}
+
+		return s.recordEventTx(ctx, tx, event)
 	})
+
+	if err != nil {
+		return err
+	}
+
+	s.invalidateUserCache(ctx)
+
+	// The account exists at this point; a mail failure must not fail the
+	// registration, the user can ask for a new link.
+	if err := s.SendEmailVerification(ctx, &user); err != nil {
+		logger.Log.Warn("failed to send verification email", zap.Error(err))
+	}
+
+	return nil
 }
 
-func (s *UserService) Login(login, password string) (string, error) {
-	user, err := s.repo.GetByLogin(login)
+// SendEmailVerification mails a single-use confirmation link bound to the
+// user's current address; a later address change invalidates it.
+func (s *UserService) SendEmailVerification(ctx context.Context, user *model.User) error {
+	if user.Email == nil || user.EmailVerifiedOn != nil {
+		return nil
+	}
 
+	token, err := GenerateOpaqueToken()
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	ttl := config.GetEmailVerifyTTL()
+	v := EmailVerification{UserID: user.ID.String(), Email: *user.Email}
+
+	if err := s.redisService.SaveEmailVerification(ctx, token, v, ttl); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      *user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Confirm your email address by opening: %s?token=%s\nThe link expires in %d hours.",
+			config.GetEmailVerifyURL(), token, int(ttl.Hours()),
+		),
+	})
+}
+
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (*model.User, error) {
+	v, err := s.redisService.ConsumeEmailVerification(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := &errors.UnauthorizedError{Reason: "invalid or expired verification token"}
+
+	if v == nil {
+		return nil, invalid
+	}
+
+	id, err := uuid.Parse(v.UserID)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var verified model.User
+
+	err = s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if user.Email == nil || *user.Email != v.Email {
+			return invalid
+		}
+
+		now := time.Now()
+		user.EmailVerifiedOn = &now
+		verified = *user
+
+		return s.recordEventTx(ctx, tx, kafka.UserEmailVerifiedEvent{
+			UserID: user.ID.String(),
+			Email:  *user.Email,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &verified, nil
+}
+
+// LoginResult carries either a token pair or, for 2FA users, a challenge
+// token to be exchanged through LoginTOTP.
+type LoginResult struct {
+	Tokens             *TokenPair
+	ChallengeToken     string
+	EnrollmentRequired bool
+}
+
+// ClientInfo describes the device a session is opened or refreshed from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+const loginChallengeTTL = 5 * time.Minute
+
+const maxChallengeAttempts = 5
+
+func (s *UserService) Login(ctx context.Context, login, password string, client ClientInfo) (*LoginResult, error) {
+	user, err := s.repo.GetByLogin(login, repository.IncludeRevoked())
+
+	if err != nil {
+		return nil, err
 	}
 
 	if !CheckPassword(user.Password, password) {
-		return "", &errors.UnauthorizedError{Reason: "invalid credentials"}
+		return nil, &errors.UnauthorizedError{Reason: "invalid credentials"}
+	}
+
+	if user.RevokedOn != nil {
+		return nil, &errors.ForbiddenError{Reason: "account revoked"}
+	}
+
+	if config.RequireVerifiedEmail() && user.Email != nil && user.EmailVerifiedOn == nil {
+		return nil, &errors.ForbiddenError{Reason: "email address not verified"}
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := GenerateOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.redisService.SaveLoginChallenge(ctx, challenge, user.ID.String(), loginChallengeTTL); err != nil {
+			return nil, err
+		}
+
+		return &LoginResult{ChallengeToken: challenge}, nil
+	}
+
+	sessionID := uuid.New().String()
+
+	tokens, restricted, err := s.issueTokens(ctx, user, sessionID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordLogin(ctx, user, sessionID, "password", client)
+
+	return &LoginResult{Tokens: tokens, EnrollmentRequired: restricted}, nil
+}
+
+// ChallengeLogin returns the login a pending 2FA challenge belongs to, so the
+// second step can be counted against the same login as the first. An unknown
+// or expired challenge is an UnauthorizedError.
+func (s *UserService) ChallengeLogin(ctx context.Context, challenge string) (string, error) {
+	userID, err := s.redisService.PeekLoginChallenge(ctx, challenge)
+	if err != nil {
+		return "", err
+	}
+
+	invalid := &errors.UnauthorizedError{Reason: "invalid or expired challenge"}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return "", invalid
 	}
 
-	return GenerateToken(user.ID.String(), user.Admin, user.Login, s.jwtKey)
+	user, err := s.repo.GetById(id)
+
+	var notFound *errors.NotFoundError
+	if stdErrors.As(err, &notFound) {
+		return "", invalid
+	} else if err != nil {
+		return "", err
+	}
+
+	return user.Login, nil
+}
+
+// LoginTOTP completes the second login phase with a TOTP or recovery code.
+func (s *UserService) LoginTOTP(ctx context.Context, challenge, code string, client ClientInfo) (*TokenPair, error) {
+	userID, attempts, err := s.redisService.GetLoginChallenge(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		return nil, &errors.UnauthorizedError{Reason: "invalid or expired challenge"}
+	}
+
+	if attempts > maxChallengeAttempts {
+		if err := s.redisService.DeleteLoginChallenge(ctx, challenge); err != nil {
+			return nil, err
+		}
+
+		return nil, &errors.UnauthorizedError{Reason: "too many attempts"}
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.twoFactor.Verify(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, &errors.UnauthorizedError{Reason: "invalid code"}
+	}
+
+	if err := s.redisService.DeleteLoginChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.New().String()
+
+	tokens, _, err := s.issueTokens(ctx, user, sessionID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordLogin(ctx, user, sessionID, "totp", client)
+
+	return tokens, nil
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already rotated
+// is treated as theft and revokes every token of its family.
+func (s *UserService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, error) {
+	rt, err := s.redisService.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if rt == nil {
+		return nil, &errors.UnauthorizedError{Reason: "invalid refresh token"}
+	}
+
+	active, err := s.redisService.IsRefreshFamilyActive(ctx, rt.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return nil, &errors.UnauthorizedError{Reason: "refresh token revoked"}
+	}
+
+	first, err := s.redisService.MarkRefreshTokenUsed(ctx, refreshToken, config.GetRefreshExpiration())
+	if err != nil {
+		return nil, err
+	}
+
+	if !first {
+		if err := s.redisService.RevokeSession(ctx, rt.UserID, rt.FamilyID); err != nil {
+			return nil, err
+		}
+
+		return nil, &errors.UnauthorizedError{Reason: "refresh token reuse detected"}
+	}
+
+	session, err := s.redisService.GetSession(ctx, rt.UserID, rt.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	validAfter, err := s.redisService.GetTokensValidAfter(ctx, rt.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil || session.IssuedAt.Before(validAfter) {
+		if err := s.redisService.RevokeSession(ctx, rt.UserID, rt.FamilyID); err != nil {
+			return nil, err
+		}
+
+		return nil, &errors.UnauthorizedError{Reason: "refresh token revoked"}
+	}
+
+	id, err := uuid.Parse(rt.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, _, err := s.issueTokens(ctx, user, rt.FamilyID, client)
+
+	return tokens, err
+}
+
+// issueTokens reports restricted=true when the admin 2FA policy limits the
+// session to enrolling a second factor.
+func (s *UserService) issueTokens(ctx context.Context, user *model.User, familyID string, client ClientInfo) (*TokenPair, bool, error) {
+	grants, err := s.roles.GrantsFor(user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	restricted := s.twoFactor.EnrollmentRequired(user, grants)
+
+	if restricted {
+		grants = Grants{Roles: []string{auth.RoleUser}, Scopes: []string{auth.ScopeMFAEnroll}}
+	}
+
+	accessToken, claims, err := GenerateToken(user, grants, familyID, s.keys)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.redisService.TrackToken(ctx, user.ID.String(), claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.redisService.TrackRefreshFamily(ctx, user.ID.String(), familyID, config.GetRefreshExpiration()); err != nil {
+		return nil, false, err
+	}
+
+	refreshToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	rt := RefreshToken{UserID: user.ID.String(), FamilyID: familyID}
+
+	if err := s.redisService.SaveRefreshToken(ctx, refreshToken, rt, config.GetRefreshExpiration()); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.saveSession(ctx, user.ID.String(), familyID, claims, client); err != nil {
+		return nil, false, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, restricted, nil
+}
+
+// saveSession records the device behind a token pair; on refresh it keeps the
+// original sign-in time and moves the session to the new access token.
+func (s *UserService) saveSession(ctx context.Context, userID, sessionID string, claims *auth.Claims, client ClientInfo) error {
+	existing, err := s.redisService.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	session := Session{
+		ID:        sessionID,
+		JTI:       claims.ID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		CreatedAt: claims.IssuedAt.Time,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: time.Now().Add(config.GetRefreshExpiration()),
+	}
+
+	if existing != nil {
+		session.CreatedAt = existing.CreatedAt
+	}
+
+	return s.redisService.SaveSession(ctx, userID, session)
+}
+
+// ListSessions returns the active sessions of a user, newest first.
+func (s *UserService) ListSessions(ctx context.Context, id uuid.UUID) ([]Session, error) {
+	sessions, err := s.redisService.ListSessions(ctx, id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return b.IssuedAt.Compare(a.IssuedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession signs a single device of the user out.
+func (s *UserService) RevokeSession(ctx context.Context, id uuid.UUID, sessionID string) error {
+	exists, err := s.redisService.SessionExists(ctx, id.String(), sessionID)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return &errors.NotFoundError{Entity: "session", Field: "id", Value: sessionID}
+	}
+
+	return s.redisService.RevokeSession(ctx, id.String(), sessionID)
+}
+
+// RevokeAllSessions signs the user out everywhere.
+func (s *UserService) RevokeAllSessions(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.repo.GetById(id); err != nil {
+		return err
+	}
+
+	return s.redisService.RevokeUserTokens(ctx, id.String())
 }
 
 func (s *UserService) GetById(id uuid.UUID) (*model.User, error) {
@@ -71,44 +530,520 @@ func (s *UserService) GetById(id uuid.UUID) (*model.User, error) {
 	return user, nil
 }
 
-func (s *UserService) GetAll(ctx context.Context) ([]model.User, error) {
-	users, err := s.repo.GetAll()
+// Revoke soft-deletes the user: the row is kept for auditing, but the account
+// drops out of lookups, cannot log in and loses every issued token.
+// It reports false when the user was already revoked.
+func (s *UserService) Revoke(ctx context.Context, id uuid.UUID, revokedBy string) (*model.User, bool, error) {
+	var (
+		revoked model.User
+		changed bool
+	)
+
+	err := s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if user.RevokedOn == nil {
+			now := time.Now()
+			user.RevokedOn = &now
+			user.RevokedBy = &revokedBy
+			user.ModifiedBy = revokedBy
+			changed = true
+		}
+
+		revoked = *user
+
+		if !changed {
+			return nil
+		}
+
+		return s.recordEventTx(ctx, tx, kafka.UserRevokedEvent{UserID: user.ID.String(), Login: user.Login})
+	})
+
+	if err != nil || !changed {
+		return &revoked, false, err
+	}
+
+	if err := s.redisService.SetUserRevoked(ctx, id.String()); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.invalidateTokens(ctx, id); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.redisService.RevokeUserTokens(ctx, id.String()); err != nil {
+		return nil, false, err
+	}
+
+	s.invalidateUserCache(ctx)
+
+	return &revoked, true, nil
+}
+
+// Restore reverses Revoke. The user has to log in again; tokens revoked
+// earlier stay invalid. It reports false when the user was not revoked.
+func (s *UserService) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*model.User, bool, error) {
+	var (
+		restored model.User
+		changed  bool
+	)
+
+	err := s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if user.RevokedOn != nil {
+			user.RevokedOn = nil
+			user.RevokedBy = nil
+			user.ModifiedBy = restoredBy
+			changed = true
+		}
+
+		restored = *user
+
+		if !changed {
+			return nil
+		}
+
+		return s.recordEventTx(ctx, tx, kafka.UserRestoredEvent{UserID: user.ID.String(), Login: user.Login})
+	})
+
+	if err != nil || !changed {
+		return &restored, false, err
+	}
+
+	if err := s.redisService.ClearUserRevoked(ctx, id.String()); err != nil {
+		return nil, false, err
+	}
+
+	s.invalidateUserCache(ctx)
+
+	return &restored, true, nil
+}
+
+func lookupOptions(includeRevoked bool) []repository.QueryOption {
+	if includeRevoked {
+		return []repository.QueryOption{repository.IncludeRevoked()}
+	}
+
+	return nil
+}
+
+func (s *UserService) Delete(ctx context.Context, id uuid.UUID) error {
+	err := s.repo.DeleteWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		return s.recordEventTx(ctx, tx, kafka.UserDeletedEvent{
+			UserID: id.String(),
+			Login:  user.Login,
+		})
+	})
+
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	s.invalidateUserCache(ctx)
+
+	return s.invalidateTokens(ctx, id)
+}
+
+// Update replaces the user. An empty password keeps the current one; a new
+// one passes the password policy and history like ChangePassword does.
+// Changing the password or the Admin flag invalidates every token issued so far.
+// adminChange says how an update treats User.Admin.
+type adminChange int
+
+const (
+	adminKeep    adminChange = iota // ignore User.Admin, keep the stored flag
+	adminDenied                     // User.Admin must match the stored flag
+	adminAllowed                    // User.Admin replaces the stored flag
+)
+
+// Update is the admin update. It may change the admin flag only when
+// canGrantAdmin is set, see auth.CanGrantAdmin.
+func (s *UserService) Update(ctx context.Context, user model.User, canGrantAdmin bool) error {
+	admin := adminDenied
+	if canGrantAdmin {
+		admin = adminAllowed
+	}
+
+	return s.update(ctx, user, admin)
+}
+
+// UpdateProfile is the self-service update; it never touches the admin flag.
+func (s *UserService) UpdateProfile(ctx context.Context, user model.User) error {
+	return s.update(ctx, user, adminKeep)
+}
+
+func (s *UserService) update(ctx context.Context, user model.User, admin adminChange) error {
+	current, err := s.repo.GetById(user.ID)
+	if err != nil {
+		return err
+	}
+
+	passwordChanged := user.Password != "" && !CheckPassword(current.Password, user.Password)
+
+	var hashedPassword string
+
+	if passwordChanged {
+		if hashedPassword, err = s.hashNewPassword("password", user.Password); err != nil {
+			return err
+		}
+	}
+
+	if admin == adminKeep {
+		user.Admin = current.Admin
+	}
+
+	err = s.repo.ModifyWithTransaction(user.ID, func(tx *gorm.DB, existing *model.User) error {
+		var changed []string
+
+		if admin == adminKeep {
+			user.Admin = existing.Admin
+		}
+
+		if passwordChanged {
+			err := s.setPasswordTx(ctx, tx, existing, "password", user.Password, hashedPassword, kafka.PasswordUpdated)
+			if err != nil {
+				return err
+			}
+
+			changed = append(changed, "password")
+		}
+
+		if existing.Name != user.Name {
+			changed = append(changed, "name")
+		}
+
+		if existing.Login != user.Login {
+			changed = append(changed, "login")
+		}
+
+		if existing.Gender != user.Gender {
+			changed = append(changed, "gender")
+		}
+
+		if existing.Admin != user.Admin {
+			if admin != adminAllowed {
+				return adminFlagForbidden()
+			}
+
+			changed = append(changed, "admin")
+		}
+
+		existing.Name = user.Name
+		existing.Login = user.Login
+		existing.Gender = user.Gender
+		existing.Admin = user.Admin
+		existing.ModifiedBy = user.ModifiedBy
+
+		return s.recordUpdateTx(ctx, tx, existing, changed)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.invalidateUserCache(ctx)
+
+	if passwordChanged {
+		return s.revokeAllTokens(ctx, user.ID)
 	}
 
-	go func() {
-		_ = s.redisService.SetCachedUsers(context.Background(), users)
-	}()
+	if current.Admin != user.Admin {
+		return s.invalidateTokens(ctx, user.ID)
+	}
 
-	return users, nil
+	return nil
 }
 
-func (s *UserService) Delete(id uuid.UUID) error {
-	err := s.repo.DeleteWithTransaction(id)
+// Patch applies only the members present in the merge patch. A new password
+// passes the password policy and history and revokes every token; only admins
+// may send one (see dto.ParseUserPatch). The admin flag also needs
+// canGrantAdmin, as in Update.
+func (s *UserService) Patch(ctx context.Context, id uuid.UUID, patch dto.UserPatch, modifiedBy string, canGrantAdmin bool) error {
+	var hashedPassword string
+	privilegesChanged := false
+
+	if patch.Password != nil {
+		hashed, err := s.hashNewPassword("password", *patch.Password)
+		if err != nil {
+			return err
+		}
+
+		hashedPassword = hashed
+	}
+
+	err := s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		var changed []string
+
+		if patch.Login != nil && *patch.Login != user.Login {
+			exists, err := s.repo.ExistsByLoginTx(tx, *patch.Login)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return &errors.ConflictError{Field: "login", Value: *patch.Login}
+			}
+
+			user.Login = *patch.Login
+			changed = append(changed, "login")
+		}
+
+		if patch.Password != nil {
+			err := s.setPasswordTx(ctx, tx, user, "password", *patch.Password, hashedPassword, kafka.PasswordUpdated)
+			if err != nil {
+				return err
+			}
+
+			changed = append(changed, "password")
+		}
+
+		if patch.Name != nil && *patch.Name != user.Name {
+			user.Name = *patch.Name
+			changed = append(changed, "name")
+		}
+
+		if patch.Gender != nil && *patch.Gender != user.Gender {
+			user.Gender = *patch.Gender
+			changed = append(changed, "gender")
+		}
+
+		if patch.ClearBirthday {
+			if user.Birthday != nil {
+				changed = append(changed, "birthday")
+			}
+
+			user.Birthday = nil
+		} else if patch.Birthday != nil {
+			if user.Birthday == nil || !user.Birthday.Equal(*patch.Birthday) {
+				changed = append(changed, "birthday")
+			}
+
+			user.Birthday = patch.Birthday
+		}
+
+		if patch.Admin != nil && *patch.Admin != user.Admin {
+			if !canGrantAdmin {
+				return adminFlagForbidden()
+			}
+
+			privilegesChanged = true
+			user.Admin = *patch.Admin
+			changed = append(changed, "admin")
+		}
+
+		user.ModifiedBy = modifiedBy
+
+		return s.recordUpdateTx(ctx, tx, user, changed)
+	})
 
 	if err != nil {
 		return err
 	}
 
+	s.invalidateUserCache(ctx)
+
+	if patch.Password != nil {
+		return s.revokeAllTokens(ctx, id)
+	}
+
+	if privilegesChanged {
+		return s.invalidateTokens(ctx, id)
+	}
+
 	return nil
 }
 
-func (s *UserService) Update(user model.User) error {
+// adminFlagForbidden rejects a change of the legacy admin flag by a caller
+// holding only users:create or users:update; the flag grants the admin role.
+func adminFlagForbidden() error {
+	return &errors.ForbiddenError{Reason: "changing the admin flag requires " + auth.PermRolesManage}
+}
+
+// invalidateTokens rejects every token of the user issued up to now, including
+// ones that were never tracked. It outlives the longest token lifetime.
+func (s *UserService) invalidateTokens(ctx context.Context, id uuid.UUID) error {
+	return s.redisService.SetTokensValidAfter(ctx, id.String(), time.Now(), config.GetRefreshExpiration())
+}
+
+// ChangePassword verifies the current password, applies the password policy and
+// history, then revokes every outstanding token of the user.
+func (s *UserService) ChangePassword(ctx context.Context, id uuid.UUID, current, next string) error {
+	verify := func(user *model.User) error {
+		if !CheckPassword(user.Password, current) {
+			return &errors.ValidationError{Fields: map[string]string{"current_password": "current password is incorrect"}}
+		}
+
+		return nil
+	}
+
+	return s.replacePassword(ctx, id, next, kafka.PasswordChanged, verify, nil)
+}
+
+// RequestPasswordReset mails a single-use reset link. It returns nil for unknown
+// addresses so callers cannot tell whether an account exists.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(email)
 
-	hashedPassword, err := HashPassword(user.Password)
+	var notFound *errors.NotFoundError
+	if stdErrors.As(err, &notFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
 
+	token, err := GenerateOpaqueToken()
 	if err != nil {
 		return err
 	}
 
-	user.Password = hashedPassword
+	ttl := config.GetPasswordResetTTL()
+
+	if err := s.redisService.SaveResetToken(ctx, token, user.ID.String(), ttl); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Use this link to reset your password: %s?token=%s\nThe link expires in %d minutes. "+
+				"If you did not request a reset, ignore this message.",
+			config.GetPasswordResetURL(), token, int(ttl.Minutes()),
+		),
+	})
+}
+
+// ResetPassword only reads the token up front. It is consumed as the last step
+// of the password transaction, so a password rejected by the policy or the
+// history leaves the link usable.
+func (s *UserService) ResetPassword(ctx context.Context, token, next string) error {
+	invalid := &errors.UnauthorizedError{Reason: "invalid or expired reset token"}
+
+	userID, err := s.redisService.GetResetToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return invalid
+	}
+
+	claim := func() error {
+		consumed, err := s.redisService.ConsumeResetToken(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		// Another reset with the same token won the race.
+		if consumed != userID {
+			return invalid
+		}
 
-	return s.repo.UpdateWithTransaction(&user)
+		return nil
+	}
+
+	return s.replacePassword(ctx, id, next, kafka.PasswordReset, func(*model.User) error { return nil }, claim)
+}
+
+// replacePassword is shared by the change and reset flows: verify runs on the
+// locked row before the policy, history and token revocation are applied.
+// claim, if set, runs last before the commit and spends one-time credentials.
+// The reason is reported in the user.password_changed event.
+func (s *UserService) replacePassword(
+	ctx context.Context,
+	id uuid.UUID,
+	next, reason string,
+	verify func(*model.User) error,
+	claim func() error,
+) error {
+	hashedPassword, err := s.hashNewPassword("new_password", next)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.ModifyWithTransaction(id, func(tx *gorm.DB, user *model.User) error {
+		if err := verify(user); err != nil {
+			return err
+		}
+
+		if err := s.setPasswordTx(ctx, tx, user, "new_password", next, hashedPassword, reason); err != nil {
+			return err
+		}
+
+		user.ModifiedBy = user.Login
+
+		if claim != nil {
+			return claim()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.revokeAllTokens(ctx, id)
+}
+
+// hashNewPassword applies the password policy; field names the request
+// member in the validation error.
+func (s *UserService) hashNewPassword(field, password string) (string, error) {
+	if errs := s.policy.Check(field, password); len(errs) > 0 {
+		return "", &errors.ValidationError{Fields: errs}
+	}
+
+	return HashPassword(password)
+}
+
+// setPasswordTx is the single place a password is replaced: it rejects reuse,
+// keeps the history and records user.password_changed. The caller saves the
+// row and calls revokeAllTokens once the transaction has committed.
+func (s *UserService) setPasswordTx(ctx context.Context, tx *gorm.DB, user *model.User, field, next, hashed, reason string) error {
+	if err := s.checkPasswordReuse(tx, user, field, next); err != nil {
+		return err
+	}
+
+	if err := s.history.AddTx(tx, user.ID, user.Password, s.policy.HistorySize); err != nil {
+		return err
+	}
+
+	user.Password = hashed
+
+	return s.recordEventTx(ctx, tx, kafka.UserPasswordChangedEvent{UserID: user.ID.String(), Reason: reason})
+}
+
+// revokeAllTokens follows a password change: tokens issued so far are
+// rejected and every session is signed out.
+func (s *UserService) revokeAllTokens(ctx context.Context, id uuid.UUID) error {
+	if err := s.invalidateTokens(ctx, id); err != nil {
+		return err
+	}
+
+	return s.redisService.RevokeUserTokens(ctx, id.String())
+}
+
+func (s *UserService) checkPasswordReuse(tx *gorm.DB, user *model.User, field, password string) error {
+	reused := &errors.ValidationError{Fields: map[string]string{field: "password was used recently"}}
+
+	if CheckPassword(user.Password, password) {
+		return reused
+	}
+
+	history, err := s.history.RecentTx(tx, user.ID, s.policy.HistorySize)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range history {
+		if CheckPassword(h.Hash, password) {
+			return reused
+		}
+	}
+
+	return nil
 }
 
-func (s *UserService) GetByLogin(login string) (*model.User, error) {
-	user, err := s.repo.GetByLogin(login)
+func (s *UserService) GetByLogin(login string, includeRevoked bool) (*model.User, error) {
+	user, err := s.repo.GetByLogin(login, lookupOptions(includeRevoked)...)
 
 	if err != nil {
 		return user, err
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"testing"
+	"userapi/internal/dto"
+	"userapi/internal/errors"
+	"userapi/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func testUser(login string, admin bool) model.User {
+	return model.User{ID: uuid.New(), Login: login, Name: login, Gender: 1, Admin: admin}
+}
+
+func assertForbidden(t *testing.T, err error) {
+	t.Helper()
+
+	var forbidden *errors.ForbiddenError
+	if !stdErrors.As(err, &forbidden) {
+		t.Fatalf("err = %v, want a ForbiddenError", err)
+	}
+}
+
+func TestRegisterAdminNeedsGrant(t *testing.T) {
+	repo := newFakeUserRepo()
+	s := newTestUserService(repo, &fakeOutbox{})
+
+	err := s.RegisterAdmin(context.Background(), testUser("bob", true), false)
+
+	assertForbidden(t, err)
+}
+
+func TestUpdateAdminFlagNeedsGrant(t *testing.T) {
+	for _, admin := range []bool{false, true} {
+		user := testUser("bob", admin)
+		repo := newFakeUserRepo(user)
+		outbox := &fakeOutbox{}
+		s := newTestUserService(repo, outbox)
+
+		update := user
+		update.Admin = !admin
+
+		assertForbidden(t, s.Update(context.Background(), update, false))
+
+		if got := repo.get(user.ID).Admin; got != admin {
+			t.Errorf("admin flag changed to %v", got)
+		}
+
+		if types := outbox.types(); len(types) != 0 {
+			t.Errorf("recorded events %v", types)
+		}
+	}
+}
+
+func TestPatchAdminFlagNeedsGrant(t *testing.T) {
+	user := testUser("bob", false)
+	repo := newFakeUserRepo(user)
+	outbox := &fakeOutbox{}
+	s := newTestUserService(repo, outbox)
+
+	admin := true
+	name := "Bobby"
+
+	err := s.Patch(context.Background(), user.ID, dto.UserPatch{Name: &name, Admin: &admin}, "support", false)
+
+	assertForbidden(t, err)
+
+	if got := repo.get(user.ID); got.Admin || got.Name != user.Name {
+		t.Errorf("user changed to admin=%v name=%q", got.Admin, got.Name)
+	}
+
+	if types := outbox.types(); len(types) != 0 {
+		t.Errorf("recorded events %v", types)
+	}
+}
+
+func TestUpdateWithoutAdminChangeNeedsNoGrant(t *testing.T) {
+	user := testUser("bob", true)
+	repo := newFakeUserRepo(user)
+	s := newTestUserService(repo, &fakeOutbox{})
+
+	update := user
+	update.Name = "Bobby"
+
+	if err := s.Update(context.Background(), update, false); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if got := repo.get(user.ID); !got.Admin || got.Name != "Bobby" {
+		t.Errorf("got admin=%v name=%q", got.Admin, got.Name)
+	}
+}
+
+func TestRegisterAppliesPasswordPolicy(t *testing.T) {
+	s := newTestUserService(newFakeUserRepo(), &fakeOutbox{})
+	s.policy = PasswordPolicy{MinLength: 8, MaxLength: MaxPasswordLength, RequireUpper: true, RequireLower: true, RequireDigit: true}
+
+	for _, password := range []string{"short1A", "alllowercase1", "NoDigitsHere", strings.Repeat("Aa1", 25)} {
+		user := testUser("bob", false)
+		user.Password = password
+
+		err := s.Register(context.Background(), user)
+
+		var validation *errors.ValidationError
+		if !stdErrors.As(err, &validation) || validation.Fields["password"] == "" {
+			t.Errorf("Register(%q) = %v, want a password ValidationError", password, err)
+		}
+	}
+}
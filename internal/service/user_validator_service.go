@@ -8,6 +8,7 @@ import (
 	"userapi/internal/repository"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 type UserValidator struct {
@@ -21,9 +22,47 @@ func NewValidator(repo repository.UserRepository) *UserValidator {
 }
 
 func (v *UserValidator) ValidateStruct(dto contract.IUserModelConvert) map[string]string {
+	errors := v.Validate(dto)
+
+	if v.loginTaken(dto) {
+		errors["login"] = "Login already taken"
+	}
+
+	if withEmail, ok := dto.(interface{ GetEmail() string }); ok {
+		if exists, _ := v.repo.ExistsByEmail(withEmail.GetEmail()); exists {
+			errors["email"] = "Email already taken"
+		}
+	}
+
+	if rejecting, ok := dto.(interface{ RejectedFields() map[string]string }); ok {
+		for field, msg := range rejecting.RejectedFields() {
+			errors[field] = msg
+		}
+	}
+
+	return errors
+}
+
+// loginTaken ignores the user an update targets, so that keeping one's own
+// login is not a conflict.
+func (v *UserValidator) loginTaken(dto contract.IUserModelConvert) bool {
+	target, ok := dto.(interface{ GetID() uuid.UUID })
+
+	if !ok || target.GetID() == uuid.Nil {
+		exists, _ := v.repo.ExistsByLogin(dto.GetLogin())
+		return exists
+	}
+
+	owner, err := v.repo.GetByLogin(dto.GetLogin(), repository.IncludeRevoked())
+
+	return err == nil && owner.ID != target.GetID()
+}
+
+// Validate checks struct tags only, for request bodies that are not user models.
+func (v *UserValidator) Validate(obj interface{}) map[string]string {
 	errors := make(map[string]string)
 
-	if err := validate.Struct(dto); err != nil {
+	if err := validate.Struct(obj); err != nil {
 		errs := err.(validator.ValidationErrors)
 
 		for _, e := range errs {
@@ -35,10 +74,6 @@ func (v *UserValidator) ValidateStruct(dto contract.IUserModelConvert) map[strin
 		}
 	}
 
-	if exists, _ := v.repo.ExistsByLogin(dto.GetLogin()); exists {
-		errors["login"] = "Login already taken"
-	}
-
 	return errors
 }
 
@@ -52,6 +87,8 @@ func buildMessage(field, tag, param string) string {
 		return fmt.Sprintf("%s must be at least %s characters", field, param)
 	case "max":
 		return fmt.Sprintf("%s must be at most %s characters", field, param)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
 	case "oneof":
 		return fmt.Sprintf("%s must be one of: %s", field, param)
 	default:
@@ -72,3 +109,23 @@ func (v *UserValidator) ValidateLoginRequest(req *dto.LoginRequest) map[string]s
 
 	return errors
 }
+
+func (v *UserValidator) ValidateRefreshRequest(req *dto.RefreshRequest) map[string]string {
+	errors := map[string]string{}
+
+	if req.RefreshToken == "" {
+		errors["refresh_token"] = "Refresh token is required"
+	}
+
+	return errors
+}
+
+func (v *UserValidator) ValidatePatch(patch *dto.UserPatch) map[string]string {
+	errors := v.Validate(patch)
+
+	if patch.IsEmpty() {
+		errors["patch"] = "patch contains no changes"
+	}
+
+	return errors
+}